@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"k8s-offline-tool/pkg/config"
@@ -8,15 +9,27 @@ import (
 	"k8s-offline-tool/pkg/ui"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		runBundle(os.Args[2:])
+		return
+	}
+
 	cfgPath := flag.String("config", "example/config-ola.yaml", "配置文件路径。e.g. config.yaml")
 	reportPath := flag.String("report", "k8s-install-summary.log", "安装报告生成路径")
+	recordDir := flag.String("record", "", "会话记录目录，记录每个节点执行的命令、输出与退出码")
+	replayDir := flag.String("replay", "", "回放 -record 生成的会话记录目录，不连接节点")
 	flag.Parse()
 
 	// 1. 加载配置
@@ -25,16 +38,79 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if *recordDir != "" {
+		cfg.RecordDir = *recordDir
+	}
+	cfg.ReplayDir = *replayDir
+
 	if err := config.ApplyDefaultsAndValidate(cfg); err != nil {
 		log.Fatal(err)
 		return
 	}
+	// 连接节点前校验资源包签名，以及资源包内容与配置是否匹配
+	for _, pkg := range cfg.ResourcePackage.Paths() {
+		signed, err := install.VerifyPackage(cfg, pkg)
+		if err != nil {
+			log.Fatalf("Failed to verify resource package: %v", err)
+		}
+		if signed {
+			fmt.Printf("✓ %s 签名校验通过\n", pkg)
+		} else {
+			fmt.Printf("⚠ allow_unsigned 已开启，跳过 %s 的签名校验\n", pkg)
+		}
+	}
+	// 支持的版本来自资源包中的版本目录，未配置的版本取目录中的最新版本
+	catalog, err := install.LoadCatalog(cfg)
+	if err != nil {
+		log.Fatalf("Failed to read resource package: %v", err)
+	}
+	if err := config.ApplyVersions(cfg, catalog, func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+	}); err != nil {
+		log.Fatal(err)
+	}
+	for _, pkg := range cfg.ResourcePackage.Paths() {
+		manifest, err := install.LoadPackageManifest(pkg)
+		if err != nil {
+			log.Fatalf("Failed to read resource package: %v", err)
+		}
+		if manifest == nil {
+			fmt.Printf("⚠ %s 未包含 %s，跳过资源包校验\n", pkg, config.PackageManifestName)
+		} else if err := manifest.Validate(cfg); err != nil {
+			log.Fatalf("%s: %v", pkg, err)
+		}
+	}
+
+	// Ctrl-C / SIGTERM 取消执行：终止远端命令、标记进行中的步骤为已取消，仍然生成报告。
+	// 第一次信号触发后恢复默认行为，再次 Ctrl-C 可强制退出。
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-runCtx.Done()
+		stop()
+	}()
+
+	// 多个资源包或按系统/架构指定资源包时先检测所有节点的系统与架构，确认每个节点都有匹配的资源包
+	if cfg.ResourcePackage.NeedsSelection() {
+		fmt.Printf("正在检测节点系统与架构以匹配资源包...\n")
+		if err := install.CheckPackageSelection(runCtx, cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.RecordDir != "" {
+		if err := os.MkdirAll(cfg.RecordDir, 0700); err != nil {
+			log.Fatalf("Failed to create record dir: %v", err)
+		}
+	}
 
 	runMode := "安装"
 	if cfg.DryRun {
 		runMode = "预检查"
 	}
 
+	if cfg.ReplayDir != "" {
+		fmt.Printf("回放会话记录: %s\n", cfg.ReplayDir)
+	}
 	if cfg.InstallMode == config.InstallModeAddonsOnly {
 		fmt.Printf("安装插件模式...\n")
 	} else {
@@ -74,20 +150,34 @@ func main() {
 	allContexts := append(masterContexts, workerContexts...)
 	_, waitTUI := ui.SetupTUI(allContexts)
 
+	// p2p 分发时所有节点共享同一个 Distributor，结束后停止节点上的临时中继
+	distributor := install.NewDistributor(cfg)
+	// 本机上传的带宽与并发限制由所有节点共享
+	uploads := install.NewUploadLimiter(cfg)
+
 	// 5. 执行 Master (顺序)
 	masterHasErr := false
 	for i, idx := range masterIndices {
 		ctx := masterContexts[i]
-		mgr, err := install.NewManager(cfg, &cfg.Nodes[idx], i+1, len(cfg.Nodes), ctx)
+		if runCtx.Err() != nil {
+			masterHasErr = true
+			break
+		}
+		mgr, err := install.NewManager(runCtx, cfg, &cfg.Nodes[idx], i+1, len(cfg.Nodes), ctx)
 		if err != nil {
 			ctx.Mu.Lock()
 			ctx.Err = fmt.Errorf("ssh 连接失败: %v", err)
+			if runCtx.Err() != nil {
+				ctx.Err = ui.ErrCancelled
+			}
 			ctx.Mu.Unlock()
 			ctx.Finish(false, 0)
 			masterHasErr = true
 			break
 		}
-		if err = mgr.Run(ctx, cfg.DryRun); err != nil {
+		mgr.SetDistributor(distributor)
+		mgr.SetUploadLimiter(uploads)
+		if err = mgr.Run(runCtx, ctx, cfg.DryRun); err != nil {
 			masterHasErr = true
 			mgr.Close()
 			break
@@ -103,25 +193,41 @@ func main() {
 			wg.Add(1)
 			go func(nodeIdx int, ctx *ui.NodeContext, runIdx int) {
 				defer wg.Done()
-				mgr, err := install.NewManager(cfg, &cfg.Nodes[nodeIdx], runIdx, len(cfg.Nodes), ctx)
+				if runCtx.Err() != nil {
+					ctx.Mu.Lock()
+					ctx.Err = ui.ErrCancelled
+					ctx.Mu.Unlock()
+					ctx.Finish(false, 0)
+					return
+				}
+				mgr, err := install.NewManager(runCtx, cfg, &cfg.Nodes[nodeIdx], runIdx, len(cfg.Nodes), ctx)
 				if err != nil {
 					ctx.Mu.Lock()
 					ctx.Err = fmt.Errorf("ssh 连接失败: %v", err)
+					if runCtx.Err() != nil {
+						ctx.Err = ui.ErrCancelled
+					}
 					ctx.Mu.Unlock()
 					ctx.Finish(false, 0)
 					return
 				}
 				defer mgr.Close()
-				_ = mgr.Run(ctx, cfg.DryRun)
+				mgr.SetDistributor(distributor)
+				mgr.SetUploadLimiter(uploads)
+				_ = mgr.Run(runCtx, ctx, cfg.DryRun)
 			}(idx, workerContexts[i], len(masterIndices)+i+1)
 		}
 		wg.Wait()
 	} else {
-		// 如果 Master 失败，标记所有未开始的节点为已跳过/失败，以解除 TUI 阻塞
+		// 如果 Master 失败或执行被取消，标记所有未开始的节点为已跳过/已取消，以解除 TUI 阻塞
+		skipErr := fmt.Errorf("因前序 Master 节点执行失败而跳过")
+		if runCtx.Err() != nil {
+			skipErr = ui.ErrCancelled
+		}
 		for _, ctx := range allContexts {
 			ctx.Mu.Lock()
 			if !ctx.Success && ctx.Err == nil {
-				ctx.Err = fmt.Errorf("因前序 Master 节点执行失败而跳过")
+				ctx.Err = skipErr
 				ctx.Mu.Unlock()
 				ctx.Finish(false, 0)
 			} else {
@@ -130,6 +236,8 @@ func main() {
 		}
 	}
 
+	distributor.Close()
+
 	// 7. 结束 TUI
 	waitTUI()
 
@@ -142,6 +250,12 @@ func main() {
 
 	// 9. 打印简要汇总
 	printSummaryFromContexts(allContexts, cfg.DryRun)
+	if cfg.RecordDir != "" {
+		fmt.Printf("\n会话记录已保存到: %s\n", cfg.RecordDir)
+	}
+	if runCtx.Err() != nil {
+		fmt.Printf("\n%s已被用户中断\n", runMode)
+	}
 }
 
 func printSummaryFromContexts(contexts []*ui.NodeContext, dryRun bool) {
@@ -201,36 +315,12 @@ func masterNodeOrder(cfg *config.Config) []int {
 func loadConfig(path string) (*config.Config, error) {
 	// 默认配置
 	cfg := &config.Config{
-		SSHPort: 22,
-		User:    "root",
-		Addons: config.AddonsConfig{
-			KubeOvn: config.AddonComponentConfig{
-				Enabled: false,
-				Version: config.KubeOvnVersions[0],
-			},
-			MultusCNI: config.AddonComponentConfig{
-				Enabled: false,
-				Version: config.MultusCNIVersions[0],
-			},
-			Hami: config.AddonComponentConfig{
-				Enabled: false,
-				Version: config.HamiVersions[0],
-			},
-			KubePrometheus: config.AddonComponentConfig{
-				Enabled: false,
-				Version: config.KubePrometheusVersions[0],
-			},
-		},
+		SSHPort:               22,
+		User:                  "root",
 		InstallMode:           config.InstallModeFull,
+		HostKeyPolicy:         config.HostKeyPolicyTOFU,
 		CommandTimeoutSeconds: int((600 * time.Second).Seconds()),
-		Versions: config.VersionConfig{
-			DockerCE:   config.DockerCEVersions[0],
-			Containerd: config.ContainerdVersions[0],
-			Runc:       config.RuncVersions[0],
-			Nerdctl:    config.NerdctlVersions[0],
-			K8s:        config.K8sVersions[0],
-		},
-		DryRun: false,
+		DryRun:                false,
 	}
 
 	data, err := os.ReadFile(path)
@@ -242,3 +332,45 @@ func loadConfig(path string) (*config.Config, error) {
 	}
 	return cfg, nil
 }
+
+// runBundle bundle 子命令：将暂存目录中已下载的离线资源打包为资源包
+func runBundle(args []string) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	src := fs.String("src", "", "已下载资源的暂存目录，目录结构与资源包解压后一致")
+	out := fs.String("out", "", "生成的资源包路径。e.g. resources-ubuntu-amd64.tar.gz")
+	name := fs.String("name", "", "写入 manifest.yaml 的资源包名称，默认为资源包文件名")
+	key := fs.String("key", "", "签名私钥路径（ssh-keygen -t ed25519 生成），为空时不签名")
+	fs.Parse(args)
+	if *src == "" || *out == "" {
+		fmt.Fprintln(os.Stderr, "用法: k8s-offline-tool bundle -src <暂存目录> -out <资源包路径> [-name <名称>] [-key <签名私钥>]")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(*out), ".tar.gz")
+	}
+
+	pm, err := install.Bundle(install.BundleOptions{Source: *src, Output: *out, Name: *name, KeyFile: *key})
+	if err != nil {
+		log.Fatalf("Failed to bundle resources: %v", err)
+	}
+	fmt.Printf("已生成资源包 %s（%d 个文件）\n", *out, len(pm.Files))
+	if *key != "" {
+		pub, err := install.SigningPublicKey(*key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("  签名公钥（填入 trusted_keys）: %s\n", pub)
+	}
+	fmt.Printf("  系统: %s\n  架构: %s\n", strings.Join(pm.OSFamilies, ", "), strings.Join(pm.Arches, ", "))
+	for _, section := range []map[string][]string{pm.Versions, pm.Charts} {
+		keys := make([]string, 0, len(section))
+		for k := range section {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("  %s: %s\n", k, strings.Join(section[k], ", "))
+		}
+	}
+}
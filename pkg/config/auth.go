@@ -0,0 +1,88 @@
+package config
+
+import "strings"
+
+// SSHAuth 描述一组 SSH 认证方式，可同时启用多种，按 公钥 -> 密码 -> keyboard-interactive 的顺序尝试
+type SSHAuth struct {
+	Password             string
+	PrivateKey           string
+	PrivateKeyPassphrase string
+	SSHAgent             bool
+	SSHAgentSocket       string
+	KeyboardInteractive  bool
+}
+
+// IsEmpty 未配置任何认证方式
+func (a SSHAuth) IsEmpty() bool {
+	return strings.TrimSpace(a.Password) == "" &&
+		strings.TrimSpace(a.PrivateKey) == "" &&
+		!a.SSHAgent &&
+		!a.KeyboardInteractive
+}
+
+// NodeAuth 返回节点最终生效的认证方式：节点配置了任意认证方式时使用节点配置，否则使用全局默认值
+func (c *Config) NodeAuth(node *NodeConfig) SSHAuth {
+	nodeAuth := SSHAuth{
+		Password:             node.Password,
+		PrivateKey:           node.PrivateKey,
+		PrivateKeyPassphrase: node.PrivateKeyPassphrase,
+		SSHAgent:             node.SSHAgent,
+		SSHAgentSocket:       node.SSHAgentSocket,
+		KeyboardInteractive:  node.KeyboardInteractive,
+	}
+	if !nodeAuth.IsEmpty() {
+		return nodeAuth
+	}
+	return c.globalAuth()
+}
+
+// BastionAuth 返回跳板机最终生效的认证方式：未单独配置时使用全局默认值
+func (c *Config) BastionAuth(bastion *BastionConfig) SSHAuth {
+	bastionAuth := SSHAuth{
+		Password:             bastion.Password,
+		PrivateKey:           bastion.PrivateKey,
+		PrivateKeyPassphrase: bastion.PrivateKeyPassphrase,
+		SSHAgent:             bastion.SSHAgent,
+		SSHAgentSocket:       bastion.SSHAgentSocket,
+		KeyboardInteractive:  bastion.KeyboardInteractive,
+	}
+	if !bastionAuth.IsEmpty() {
+		return bastionAuth
+	}
+	return c.globalAuth()
+}
+
+// NodeBastions 返回节点使用的跳板机链路：节点显式配置（包括空列表）时优先，否则使用全局配置
+func (c *Config) NodeBastions(node *NodeConfig) []BastionConfig {
+	if node.Bastion != nil {
+		return node.Bastion
+	}
+	return c.Bastion
+}
+
+func (c *Config) globalAuth() SSHAuth {
+	return SSHAuth{
+		Password:             c.Password,
+		PrivateKey:           c.PrivateKey,
+		PrivateKeyPassphrase: c.PrivateKeyPassphrase,
+		SSHAgent:             c.SSHAgent,
+		SSHAgentSocket:       c.SSHAgentSocket,
+		KeyboardInteractive:  c.KeyboardInteractive,
+	}
+}
+
+// NeedsSudo 非 root 用户登录时需要 sudo 提权
+func (c *Config) NeedsSudo() bool {
+	return c.User != "" && c.User != "root"
+}
+
+// NodeSudoPassword 返回节点的 sudo 密码：节点配置 > 全局配置 > SSH 登录密码
+func (c *Config) NodeSudoPassword(node *NodeConfig) string {
+	if node.SudoPassword != "" {
+		return node.SudoPassword
+	}
+	if c.SudoPassword != "" {
+		return c.SudoPassword
+	}
+	return c.NodeAuth(node).Password
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Catalog 版本目录：支持的软件版本、chart 文件名与镜像分组，随资源包的 manifest.yaml 发布。
+// 资源包未提供的条目使用内置的版本常量与 images.yaml
+type Catalog struct {
+	Versions    map[string][]string          `yaml:"versions"`              // dockerce / containerd / runc / nerdctl / k8s -> 包含的版本
+	Charts      map[string][]string          `yaml:"charts"`                // chart 名称 -> 包含的版本
+	ChartFiles  map[string]map[string]string `yaml:"chart_files,omitempty"` // chart 名称 -> 版本 -> chart 文件名
+	ImageGroups map[string][]string          `yaml:"image_groups"`          // 与 images.yaml 相同的镜像分组
+}
+
+// DefaultCatalog 内置的版本目录
+func DefaultCatalog() (*Catalog, error) {
+	groups, err := ImagesByGroup()
+	if err != nil {
+		return nil, err
+	}
+	return &Catalog{
+		Versions: map[string][]string{
+			"dockerce":   DockerCEVersions,
+			"containerd": ContainerdVersions,
+			"runc":       RuncVersions,
+			"nerdctl":    NerdctlVersions,
+			"k8s":        K8sVersions,
+		},
+		Charts: map[string][]string{
+			"kube-ovn":              KubeOvnVersions,
+			"multus-cni":            MultusCNIVersions,
+			"hami":                  HamiVersions,
+			"hami-webui":            HamiWebUIVersions,
+			"kube-prometheus-stack": KubePrometheusVersions,
+		},
+		ChartFiles: map[string]map[string]string{
+			"kube-ovn":              {KubeOvnVersions[0]: DefaultKubeOvnChart},
+			"hami":                  {HamiVersions[0]: DefaultHamiChart},
+			"hami-webui":            {HamiWebUIVersions[0]: DefaultHamiWebUIChart},
+			"kube-prometheus-stack": {KubePrometheusVersions[0]: DefaultKubePrometheusStackChart},
+		},
+		ImageGroups: groups,
+	}, nil
+}
+
+// MergeCatalogs 合并多个资源包的版本目录，同一条目取并集；nil 表示资源包没有 manifest.yaml，按内置目录处理。
+// 所有资源包都未提供的条目由内置目录补齐
+func MergeCatalogs(catalogs ...*Catalog) (*Catalog, error) {
+	builtin, err := DefaultCatalog()
+	if err != nil {
+		return nil, err
+	}
+	merged := &Catalog{
+		Versions:    map[string][]string{},
+		Charts:      map[string][]string{},
+		ChartFiles:  map[string]map[string]string{},
+		ImageGroups: map[string][]string{},
+	}
+	for _, c := range catalogs {
+		if c == nil {
+			c = builtin
+		}
+		merged.add(c, false)
+	}
+	merged.add(builtin, true)
+	return merged, nil
+}
+
+// add 将 c 的条目并入目录，onlyMissing 时只补齐目录中没有的条目
+func (c *Catalog) add(other *Catalog, onlyMissing bool) {
+	for _, pair := range []struct{ dst, src map[string][]string }{
+		{c.Versions, other.Versions},
+		{c.Charts, other.Charts},
+		{c.ImageGroups, other.ImageGroups},
+	} {
+		for key, values := range pair.src {
+			if _, ok := pair.dst[key]; ok && onlyMissing {
+				continue
+			}
+			list := pair.dst[key]
+			for _, v := range values {
+				if !slices.Contains(list, v) {
+					list = append(list, v)
+				}
+			}
+			pair.dst[key] = list
+		}
+	}
+	for chart, files := range other.ChartFiles {
+		if _, ok := c.ChartFiles[chart]; ok && onlyMissing {
+			continue
+		}
+		if c.ChartFiles[chart] == nil {
+			c.ChartFiles[chart] = map[string]string{}
+		}
+		for version, file := range files {
+			c.ChartFiles[chart][version] = file
+		}
+	}
+}
+
+// ChartFile chart 指定版本的文件名，version 为空时取目录中的最新版本
+func (c *Catalog) ChartFile(chart, version string) (string, error) {
+	if version == "" {
+		version = LatestVersion(c.Charts[chart])
+	}
+	if file, ok := c.ChartFiles[chart][version]; ok {
+		return file, nil
+	}
+	return "", fmt.Errorf("version catalog has no chart file for %s %s", chart, version)
+}
+
+// LatestVersion 按数字逐段比较取最新版本，如 1.34.4 新于 1.9.0
+func LatestVersion(versions []string) string {
+	latest := ""
+	for _, v := range versions {
+		if latest == "" || compareVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// compareVersions 忽略 "v" 前缀，以 "." 与 "-" 分段，数字段按数值比较，其余按字符串比较
+func compareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(strings.TrimPrefix(v, "v"), func(r rune) bool { return r == '.' || r == '-' })
+	}
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ai, aerr := strconv.Atoi(as[i])
+		bi, berr := strconv.Atoi(bs[i])
+		if aerr == nil && berr == nil {
+			if ai != bi {
+				return cmp.Compare(ai, bi)
+			}
+			continue
+		}
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// ApplyVersions 未配置的版本取版本目录中的最新版本（按版本号比较，不是目录中列出的第一个），
+// 通过 logf 输出选用的版本，并检查配置的版本是否在目录中
+func ApplyVersions(cfg *Config, catalog *Catalog, logf func(format string, args ...any)) error {
+	versions := []struct {
+		name      string
+		value     *string
+		supported []string
+	}{
+		{"DockerCE", &cfg.Versions.DockerCE, catalog.Versions["dockerce"]},
+		{"Containerd", &cfg.Versions.Containerd, catalog.Versions["containerd"]},
+		{"Runc", &cfg.Versions.Runc, catalog.Versions["runc"]},
+		{"Nerdctl", &cfg.Versions.Nerdctl, catalog.Versions["nerdctl"]},
+		{"Kubernetes", &cfg.Versions.K8s, catalog.Versions["k8s"]},
+		{"Kube-OVN", &cfg.Addons.KubeOvn.Version, catalog.Charts["kube-ovn"]},
+		{"Multus CNI", &cfg.Addons.MultusCNI.Version, catalog.Charts["multus-cni"]},
+		{"HAMI", &cfg.Addons.Hami.Version, catalog.Charts["hami"]},
+		{"Kube Prometheus Stack", &cfg.Addons.KubePrometheus.Version, catalog.Charts["kube-prometheus-stack"]},
+	}
+
+	var picked []string
+	for _, v := range versions {
+		if *v.value == "" {
+			*v.value = LatestVersion(v.supported)
+			if *v.value != "" {
+				picked = append(picked, fmt.Sprintf("%s %s", v.name, *v.value))
+			}
+			continue
+		}
+		if !stringInSlice(*v.value, v.supported) {
+			return fmt.Errorf("Error: %s version %s is not supported (available: %s).", v.name, *v.value, listOrNone(v.supported))
+		}
+	}
+	if len(picked) > 0 && logf != nil {
+		logf("未配置的版本使用版本目录中的最新版本: %s", strings.Join(picked, ", "))
+	}
+	return nil
+}
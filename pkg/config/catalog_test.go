@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApplyVersions(t *testing.T) {
+	pm, err := ParsePackageManifest([]byte(`
+versions:
+  k8s: ["1.9.0", "1.34.4", "1.33.7"]
+charts:
+  hami: ["2.8.0"]
+chart_files:
+  hami: {"2.8.0": "hami-2.8.0.tgz"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := MergeCatalogs(&pm.Catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		versions VersionConfig
+		hami     string
+		wantK8s  string
+		wantHami string
+		wantErr  string
+	}{
+		{name: "Defaults to latest catalog versions", wantK8s: "1.34.4", wantHami: "2.8.0"},
+		{name: "Older version in catalog", versions: VersionConfig{K8s: "1.33.7"}, hami: "2.8.0", wantK8s: "1.33.7", wantHami: "2.8.0"},
+		{name: "Version missing from catalog", hami: "2.7.1", wantErr: "HAMI version 2.7.1 is not supported (available: 2.8.0)"},
+		{name: "Missing entries use builtin catalog", versions: VersionConfig{DockerCE: "29.2.0"}, wantK8s: "1.34.4", wantHami: "2.8.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Versions: tt.versions, Addons: AddonsConfig{Hami: AddonComponentConfig{Version: tt.hami}}}
+			var logged string
+			err := ApplyVersions(cfg, catalog, func(format string, args ...any) { logged = fmt.Sprintf(format, args...) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyVersions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Versions.K8s != tt.wantK8s || cfg.Addons.Hami.Version != tt.wantHami || cfg.Versions.DockerCE != DockerCEVersions[0] {
+				t.Errorf("versions = %+v, hami = %s", cfg.Versions, cfg.Addons.Hami.Version)
+			}
+			if tt.versions.K8s == "" && !strings.Contains(logged, "Kubernetes "+tt.wantK8s) {
+				t.Errorf("logged %q, want picked Kubernetes version", logged)
+			}
+		})
+	}
+
+	if file, err := catalog.ChartFile("hami", ""); err != nil || file != "hami-2.8.0.tgz" {
+		t.Errorf("ChartFile(hami) = %q, %v", file, err)
+	}
+	if file, err := catalog.ChartFile("kube-ovn", KubeOvnVersions[0]); err != nil || file != DefaultKubeOvnChart {
+		t.Errorf("ChartFile(kube-ovn) = %q, %v; want builtin fallback", file, err)
+	}
+}
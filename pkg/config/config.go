@@ -2,19 +2,47 @@ package config
 
 type Config struct {
 	// 全局配置
-	Registry        RegistryConfig `yaml:"registry"`
-	Versions        VersionConfig  `yaml:"versions"`
-	Addons          AddonsConfig   `yaml:"addons"`
-	HA              HAConfig       `yaml:"ha"`
-	ResourcePackage string         `yaml:"resource_package"` // 资源包本地路径
+	Registry RegistryConfig `yaml:"registry"`
+	Versions VersionConfig  `yaml:"versions"`
+	Addons   AddonsConfig   `yaml:"addons"`
+	HA       HAConfig       `yaml:"ha"`
+	// 资源包本地路径、已解压的资源目录或 http(s) 镜像地址，可为单个路径、列表或以系统家族/架构为键的映射，见 ResourcePackages
+	ResourcePackage ResourcePackages `yaml:"resource_package"`
+	// 资源包签名校验：可信的 ssh-ed25519 公钥（authorized_keys 格式），未签名的资源包需显式允许
+	TrustedKeys   []string `yaml:"trusted_keys"`
+	AllowUnsigned bool     `yaml:"allow_unsigned"`
 
 	// 默认 SSH 配置 (如果 Node 中未指定则使用此默认值)
 	SSHPort int    `yaml:"ssh_port"`
 	User    string `yaml:"user"`
+	// 默认 SSH 认证方式，节点配置了任意一种认证方式时整体覆盖此默认值
+	Password             string `yaml:"password"`
+	PrivateKey           string `yaml:"private_key"`            // 私钥文件路径
+	PrivateKeyPassphrase string `yaml:"private_key_passphrase"` // 私钥口令（可选）
+	SSHAgent             bool   `yaml:"ssh_agent"`              // 使用 ssh-agent 中的密钥
+	SSHAgentSocket       string `yaml:"ssh_agent_socket"`       // ssh-agent socket 路径，默认读取 SSH_AUTH_SOCK
+	KeyboardInteractive  bool   `yaml:"keyboard_interactive"`   // 使用 password 应答 keyboard-interactive 认证
+	// 非 root 用户登录时的 sudo 密码，为空时回退到 SSH 登录密码，仍为空则要求 NOPASSWD
+	SudoPassword string `yaml:"sudo_password"`
+	// 主机公钥校验策略：strict / tofu / insecure
+	HostKeyPolicy  string `yaml:"host_key_policy"`
+	KnownHostsFile string `yaml:"known_hosts_file"` // 可选：自定义 known_hosts 路径
+	// 跳板机链路，按顺序逐跳连接，最后一跳连接目标节点
+	Bastion []BastionConfig `yaml:"bastion"`
 	// 命令执行超时（秒）
 	CommandTimeoutSeconds int `yaml:"command_timeout_seconds"`
+	// SSH 保活间隔（秒），默认 15，配置为负数关闭保活
+	SSHKeepaliveSeconds int `yaml:"ssh_keepalive_seconds"`
+	// SSH 断开后的重连次数，默认 3，配置为负数不重连
+	SSHReconnectAttempts int `yaml:"ssh_reconnect_attempts"`
 	// 安装模式：full(从零安装) 或 addons-only(仅部署组件)
 	InstallMode string `yaml:"install_mode"`
+	// 离线资源分发方式
+	Distribution DistributionConfig `yaml:"distribution"`
+	// 本机上传资源包的总带宽上限，所有节点共享，如 "200MB/s"，为空不限速
+	UploadRateLimit string `yaml:"upload_rate_limit"`
+	// 同时从本机上传资源包的最大节点数，0 表示不限制
+	MaxConcurrentUploads int `yaml:"max_concurrent_uploads"`
 
 	// 节点列表
 	Nodes             []NodeConfig `yaml:"nodes"`
@@ -23,6 +51,11 @@ type Config struct {
 
 	// 仅执行预检查，不执行安装动作
 	DryRun bool `yaml:"dry_run"`
+
+	// 会话记录目录：每个节点执行的命令、输出与退出码写入 <ip>.jsonl，用于复现现场问题
+	RecordDir string `yaml:"record_dir"`
+	// 回放会话记录的目录，按记录应答命令而不连接节点，仅由命令行 -replay 指定
+	ReplayDir string `yaml:"-"`
 }
 
 type NodeConfig struct {
@@ -32,6 +65,33 @@ type NodeConfig struct {
 	IsMaster        bool   `yaml:"is_master"`
 	IsPrimaryMaster bool   `yaml:"is_primary_master"`
 	Interface       string `yaml:"interface"`
+	// 在运行工具的本机上直接安装，不经过 SSH（需以 root 运行）
+	Local bool `yaml:"local"`
+
+	// 可选：覆盖全局 SSH 认证方式
+	PrivateKey           string `yaml:"private_key"`
+	PrivateKeyPassphrase string `yaml:"private_key_passphrase"`
+	SSHAgent             bool   `yaml:"ssh_agent"`
+	SSHAgentSocket       string `yaml:"ssh_agent_socket"`
+	KeyboardInteractive  bool   `yaml:"keyboard_interactive"`
+	SudoPassword         string `yaml:"sudo_password"` // 可选：覆盖全局 sudo 密码
+
+	// 可选：覆盖全局跳板机链路，配置为 [] 表示直连
+	Bastion []BastionConfig `yaml:"bastion"`
+}
+
+type BastionConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // 默认 22
+	User string `yaml:"user"` // 默认使用全局 user
+
+	// 可选：跳板机认证方式，未配置时使用全局认证方式
+	Password             string `yaml:"password"`
+	PrivateKey           string `yaml:"private_key"`
+	PrivateKeyPassphrase string `yaml:"private_key_passphrase"`
+	SSHAgent             bool   `yaml:"ssh_agent"`
+	SSHAgentSocket       string `yaml:"ssh_agent_socket"`
+	KeyboardInteractive  bool   `yaml:"keyboard_interactive"`
 }
 
 type RegistryConfig struct {
@@ -62,6 +122,16 @@ type AddonComponentConfig struct {
 	Version string `yaml:"version"`
 }
 
+// DistributionConfig 离线资源分发配置
+type DistributionConfig struct {
+	Mode      string `yaml:"mode"`       // direct: 本机逐个上传；p2p: 先上传到种子节点，其余节点从已就绪节点拉取
+	Seeds     int    `yaml:"seeds"`      // p2p: 由本机直接上传的种子节点数
+	Fanout    int    `yaml:"fanout"`     // p2p: 每个已就绪节点同时服务的下载数
+	RelayPort int    `yaml:"relay_port"` // p2p: 节点上临时 HTTP 中继的端口
+	// 本机直传方式：file 先上传资源包再解压；stream 经 SSH 流式解压，节点上不保留资源包
+	UploadMode string `yaml:"upload_mode"`
+}
+
 type HAConfig struct {
 	Enabled   bool   `yaml:"enabled"`
 	VirtualIP string `yaml:"virtual_ip"`
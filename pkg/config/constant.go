@@ -1,5 +1,6 @@
 package config
 
+// 内置的版本目录，资源包 manifest.yaml 未提供版本目录时使用，见 Catalog
 var (
 	DockerCEVersions   = []string{"29.2.0"}
 	ContainerdVersions = []string{"2.2.1"}
@@ -10,6 +11,7 @@ var (
 	KubeOvnVersions        = []string{"1.15.2"}
 	MultusCNIVersions      = []string{"snapshot-thick"}
 	HamiVersions           = []string{"2.7.1"}
+	HamiWebUIVersions      = []string{"1.0.5"}
 	KubePrometheusVersions = []string{"81.6.0"}
 )
 
@@ -23,6 +25,28 @@ const (
 
 var SupportedInstallModes = []string{InstallModeFull, InstallModeAddonsOnly, InstallModePreInit}
 
+const (
+	HostKeyPolicyStrict   = "strict"
+	HostKeyPolicyTOFU     = "tofu"
+	HostKeyPolicyInsecure = "insecure"
+)
+
+var SupportedHostKeyPolicies = []string{HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyInsecure}
+
+const (
+	DistributionModeDirect = "direct"
+	DistributionModeP2P    = "p2p"
+)
+
+var SupportedDistributionModes = []string{DistributionModeDirect, DistributionModeP2P}
+
+const (
+	UploadModeFile   = "file"
+	UploadModeStream = "stream"
+)
+
+var SupportedUploadModes = []string{UploadModeFile, UploadModeStream}
+
 const (
 	DefaultPauseImage       = "pause:3.10.1"
 	DefaultK8sImageRegistry = "registry.aliyuncs.com"
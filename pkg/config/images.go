@@ -10,9 +10,15 @@ import (
 //go:embed images.yaml
 var imagesYAML []byte
 
+// ImagesByGroup 内置 images.yaml 中的镜像分组
 func ImagesByGroup() (map[string][]string, error) {
+	return ParseImageGroups(imagesYAML)
+}
+
+// ParseImageGroups 解析 images.yaml 格式的镜像分组
+func ParseImageGroups(data []byte) (map[string][]string, error) {
 	groups := make(map[string][]string)
-	if err := yaml.Unmarshal(imagesYAML, &groups); err != nil {
+	if err := yaml.Unmarshal(data, &groups); err != nil {
 		return nil, fmt.Errorf("parse images.yaml failed: %w", err)
 	}
 	return groups, nil
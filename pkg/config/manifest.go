@@ -0,0 +1,139 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PackageManifestName  = "manifest.yaml"     // 资源包根目录下的描述文件
+	PackageSignatureName = "manifest.yaml.sig" // manifest.yaml 的 ed25519 分离签名
+)
+
+// 资源包支持的系统家族，与节点使用的安装策略对应
+const (
+	OSFamilyUbuntu    = "ubuntu"    // Ubuntu / Debian，apt
+	OSFamilyFedora    = "fedora"    // Fedora / CentOS，rpm
+	OSFamilyOpenEuler = "openeuler" // openEuler，rpm
+)
+
+// PackageManifest 资源包内容描述，用于在连接节点前校验资源包与配置是否匹配，其中的版本目录决定支持的版本
+type PackageManifest struct {
+	Name       string   `yaml:"name"`
+	OSFamilies []string `yaml:"os_families"`
+	Arches     []string `yaml:"arches"`
+	Catalog    `yaml:",inline"`
+	Files      map[string]string `yaml:"files"` // 文件路径 -> sha256，由 bundle 生成
+	// 组件 -> 资源包中的目录，{arch}、{pkg} 为节点架构与包格式占位符，由 bundle 生成
+	Components map[string][]string `yaml:"components,omitempty"`
+}
+
+// ParsePackageManifest 解析 manifest.yaml
+func ParsePackageManifest(data []byte) (*PackageManifest, error) {
+	var pm PackageManifest
+	if err := yaml.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", PackageManifestName, err)
+	}
+	return &pm, nil
+}
+
+// Validate 检查配置要求的软件版本、插件 chart 与镜像分组是否都在资源包中，一次列出全部缺失项
+func (pm *PackageManifest) Validate(cfg *Config) error {
+	var missing []string
+	requireVersion := func(key, version string) {
+		if !slices.Contains(pm.Versions[key], version) {
+			missing = append(missing, fmt.Sprintf("versions.%s: %s (package has %s)", key, version, listOrNone(pm.Versions[key])))
+		}
+	}
+	requireChart := func(name, version, imageGroup string) {
+		if !slices.Contains(pm.Charts[name], version) {
+			missing = append(missing, fmt.Sprintf("charts.%s: %s (package has %s)", name, version, listOrNone(pm.Charts[name])))
+		}
+		if _, ok := pm.ImageGroups[imageGroup]; !ok {
+			missing = append(missing, fmt.Sprintf("image_groups.%s", imageGroup))
+		}
+	}
+
+	if cfg.InstallMode != InstallModeAddonsOnly {
+		requireVersion("dockerce", cfg.Versions.DockerCE)
+		requireVersion("containerd", cfg.Versions.Containerd)
+		requireVersion("runc", cfg.Versions.Runc)
+		requireVersion("nerdctl", cfg.Versions.Nerdctl)
+		requireVersion("k8s", cfg.Versions.K8s)
+	}
+	if cfg.InstallMode == InstallModeFull {
+		if _, ok := pm.ImageGroups["k8s-images"]; !ok {
+			missing = append(missing, "image_groups.k8s-images")
+		}
+	}
+	// 与安装步骤一致：kube-ovn、multus 在 full 与 addons-only 模式部署，HAMi 与监控仅在 addons-only 模式部署
+	if cfg.InstallMode != InstallModePreInit {
+		if cfg.Addons.KubeOvn.Enabled {
+			requireChart("kube-ovn", cfg.Addons.KubeOvn.Version, "kube-ovn-images")
+		}
+		if cfg.Addons.MultusCNI.Enabled {
+			requireChart("multus-cni", cfg.Addons.MultusCNI.Version, "multus-cni-images")
+		}
+	}
+	if cfg.InstallMode == InstallModeAddonsOnly {
+		if cfg.Addons.Hami.Enabled {
+			requireChart("hami", cfg.Addons.Hami.Version, "hami-images")
+		}
+		if cfg.Addons.KubePrometheus.Enabled {
+			requireChart("kube-prometheus-stack", cfg.Addons.KubePrometheus.Version, "kube-prometheus-stack-images")
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("resource package does not contain what the config requires:\n  - %s", strings.Join(missing, "\n  - "))
+	}
+	return nil
+}
+
+// CheckNode 检查资源包是否支持节点的系统家族与架构
+func (pm *PackageManifest) CheckNode(osFamily, arch string) error {
+	var missing []string
+	if !slices.Contains(pm.OSFamilies, osFamily) {
+		missing = append(missing, fmt.Sprintf("os family %s (package has %s)", osFamily, listOrNone(pm.OSFamilies)))
+	}
+	if !slices.Contains(pm.Arches, arch) {
+		missing = append(missing, fmt.Sprintf("arch %s (package has %s)", arch, listOrNone(pm.Arches)))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("resource package does not support %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ParseTrustedKeys 解析 trusted_keys 中 authorized_keys 格式的 ssh-ed25519 公钥
+func ParseTrustedKeys(keys []string) ([]ed25519.PublicKey, error) {
+	var parsed []ed25519.PublicKey
+	for _, key := range keys {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %q is invalid: %v", key, err)
+		}
+		cryptoPub, ok := pub.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("trusted key %q is not an ed25519 key", key)
+		}
+		edPub, ok := cryptoPub.CryptoPublicKey().(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("trusted key %q is not an ed25519 key", key)
+		}
+		parsed = append(parsed, edPub)
+	}
+	return parsed, nil
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const testManifest = `
+name: offline-1.0
+os_families: [ubuntu, openeuler]
+arches: [amd64, arm64]
+versions:
+  dockerce: ["26.1.4"]
+  containerd: ["1.7.18"]
+  runc: ["1.1.13"]
+  nerdctl: ["1.7.6"]
+  k8s: ["1.28.15", "1.30.14"]
+charts:
+  kube-ovn: ["v1.13.0"]
+image_groups:
+  k8s-images: []
+  kube-ovn-images: []
+`
+
+func TestPackageManifestValidate(t *testing.T) {
+	pm, err := ParsePackageManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := VersionConfig{DockerCE: "26.1.4", Containerd: "1.7.18", Runc: "1.1.13", Nerdctl: "1.7.6", K8s: "1.30.14"}
+	tests := []struct {
+		name    string
+		cfg     *Config
+		missing []string
+	}{
+		{
+			name: "Full mode with kube-ovn",
+			cfg: &Config{
+				InstallMode: InstallModeFull,
+				Versions:    versions,
+				Addons:      AddonsConfig{KubeOvn: AddonComponentConfig{Enabled: true, Version: "v1.13.0"}},
+			},
+		},
+		{
+			name: "Missing versions and addons are all listed",
+			cfg: &Config{
+				InstallMode: InstallModeFull,
+				Versions:    VersionConfig{DockerCE: "26.1.4", Containerd: "1.7.18", Runc: "1.1.13", Nerdctl: "1.7.6", K8s: "1.31.0"},
+				Addons: AddonsConfig{
+					KubeOvn:   AddonComponentConfig{Enabled: true, Version: "v1.14.0"},
+					MultusCNI: AddonComponentConfig{Enabled: true, Version: "v4.1.0"},
+				},
+			},
+			missing: []string{
+				"versions.k8s: 1.31.0 (package has 1.28.15, 1.30.14)",
+				"charts.kube-ovn: v1.14.0 (package has v1.13.0)",
+				"charts.multus-cni: v4.1.0 (package has none)",
+				"image_groups.multus-cni-images",
+			},
+		},
+		{
+			name: "Pre-init ignores addons and k8s images",
+			cfg: &Config{
+				InstallMode: InstallModePreInit,
+				Versions:    versions,
+				Addons:      AddonsConfig{MultusCNI: AddonComponentConfig{Enabled: true, Version: "v4.1.0"}},
+			},
+		},
+		{
+			name: "Addons-only ignores runtime versions",
+			cfg: &Config{
+				InstallMode: InstallModeAddonsOnly,
+				Addons:      AddonsConfig{Hami: AddonComponentConfig{Enabled: true, Version: "2.6.0"}},
+			},
+			missing: []string{"charts.hami: 2.6.0 (package has none)", "image_groups.hami-images"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pm.Validate(tt.cfg)
+			if len(tt.missing) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() should fail")
+			}
+			if got := strings.Count(err.Error(), "\n  - "); got != len(tt.missing) {
+				t.Errorf("Validate() listed %d items, want %d: %v", got, len(tt.missing), err)
+			}
+			for _, item := range tt.missing {
+				if !strings.Contains(err.Error(), "\n  - "+item) {
+					t.Errorf("Validate() error missing %q: %v", item, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPackageManifestCheckNode(t *testing.T) {
+	pm, err := ParsePackageManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		osFamily, arch string
+		wantErr        bool
+	}{
+		{OSFamilyUbuntu, "amd64", false},
+		{OSFamilyOpenEuler, "arm64", false},
+		{OSFamilyFedora, "amd64", true},
+		{OSFamilyUbuntu, "riscv64", true},
+	}
+	for _, tt := range tests {
+		if err := pm.CheckNode(tt.osFamily, tt.arch); (err != nil) != tt.wantErr {
+			t.Errorf("CheckNode(%s, %s) error = %v, wantErr %v", tt.osFamily, tt.arch, err, tt.wantErr)
+		}
+	}
+}
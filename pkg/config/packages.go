@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SupportedOSFamilies resource_package 映射键中可用的系统家族
+var SupportedOSFamilies = []string{OSFamilyUbuntu, OSFamilyFedora, OSFamilyOpenEuler}
+
+// ResourcePackage 一个资源包及其适用的节点，OSFamily、Arch 为空表示不限。
+// Path 可为 tar.gz 资源包、已解压的资源目录或发布已解压资源的 http(s) 镜像地址
+type ResourcePackage struct {
+	Path     string
+	OSFamily string
+	Arch     string
+}
+
+// ResourcePackages resource_package 配置，支持三种写法：
+// 单个路径；路径列表，按各资源包 manifest.yaml 中的 os_families/arches 匹配节点；
+// 映射，键为 "<系统家族>/<架构>"、"<系统家族>" 或 "<架构>"
+type ResourcePackages []ResourcePackage
+
+func (p *ResourcePackages) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		var path string
+		if err := value.Decode(&path); err != nil {
+			return err
+		}
+		*p = ResourcePackages{{Path: path}}
+	case yaml.SequenceNode:
+		var paths []string
+		if err := value.Decode(&paths); err != nil {
+			return err
+		}
+		*p = nil
+		for _, path := range paths {
+			*p = append(*p, ResourcePackage{Path: path})
+		}
+	case yaml.MappingNode:
+		*p = nil
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			var key, path string
+			if err := value.Content[i].Decode(&key); err != nil {
+				return err
+			}
+			if err := value.Content[i+1].Decode(&path); err != nil {
+				return err
+			}
+			osFamily, arch, err := parsePackageKey(key)
+			if err != nil {
+				return err
+			}
+			*p = append(*p, ResourcePackage{Path: path, OSFamily: osFamily, Arch: arch})
+		}
+	default:
+		return fmt.Errorf("resource_package must be a path, a list or a map")
+	}
+	return nil
+}
+
+// parsePackageKey 解析映射键，不带 "/" 时按是否为已知系统家族区分系统家族与架构
+func parsePackageKey(key string) (string, string, error) {
+	osFamily, arch, found := strings.Cut(key, "/")
+	if !found {
+		if slices.Contains(SupportedOSFamilies, key) {
+			return key, "", nil
+		}
+		osFamily, arch = "", key
+	}
+	if found && !slices.Contains(SupportedOSFamilies, osFamily) {
+		return "", "", fmt.Errorf("resource_package key %q: os family %s is not supported", key, osFamily)
+	}
+	if (found && arch == "") || strings.ContainsAny(arch, "/ ") {
+		return "", "", fmt.Errorf("resource_package key %q is invalid", key)
+	}
+	return osFamily, arch, nil
+}
+
+// IsMirrorURL 资源来源是否为 http(s) 镜像地址
+func IsMirrorURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// checkMirrorURL 镜像地址须带主机名，且不能带查询参数，文件路径直接拼接在地址之后
+func checkMirrorURL(path string) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("resource_package %s is not a valid url: %v", path, err)
+	}
+	if u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("resource_package %s must be a plain http(s) url without query", path)
+	}
+	return nil
+}
+
+// Paths 所有资源包路径，按配置顺序去重
+func (p ResourcePackages) Paths() []string {
+	var paths []string
+	for _, pkg := range p {
+		if !slices.Contains(paths, pkg.Path) {
+			paths = append(paths, pkg.Path)
+		}
+	}
+	return paths
+}
+
+// Keyed 是否为映射写法
+func (p ResourcePackages) Keyed() bool {
+	for _, pkg := range p {
+		if pkg.OSFamily != "" || pkg.Arch != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsSelection 是否需要按节点的系统与架构选择资源包：多个资源包，或映射写法限定了系统家族/架构
+func (p ResourcePackages) NeedsSelection() bool {
+	return len(p) > 1 || p.Keyed()
+}
+
+// Select 为节点选择资源包。映射按 系统家族/架构 > 架构 > 系统家族 的优先级匹配；
+// 只有一个资源包时直接使用；列表按顺序选择 supports 返回 true 的第一个资源包
+func (p ResourcePackages) Select(osFamily, arch string, supports func(path string) (bool, error)) (string, error) {
+	if p.Keyed() {
+		best, bestScore := "", 0
+		for _, pkg := range p {
+			if (pkg.OSFamily != "" && pkg.OSFamily != osFamily) || (pkg.Arch != "" && pkg.Arch != arch) {
+				continue
+			}
+			score := 1
+			if pkg.Arch != "" {
+				score += 2
+			}
+			if pkg.OSFamily != "" {
+				score++
+			}
+			if score > bestScore {
+				best, bestScore = pkg.Path, score
+			}
+		}
+		if best == "" {
+			return "", fmt.Errorf("no resource_package configured for %s/%s", osFamily, arch)
+		}
+		return best, nil
+	}
+	if len(p) == 1 {
+		return p[0].Path, nil
+	}
+	for _, pkg := range p {
+		ok, err := supports(pkg.Path)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return pkg.Path, nil
+		}
+	}
+	return "", fmt.Errorf("none of the resource packages supports %s/%s", osFamily, arch)
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResourcePackagesSelect(t *testing.T) {
+	manifests := map[string][]string{
+		"ubuntu-amd64.tar.gz":    {"ubuntu/amd64"},
+		"openeuler-arm64.tar.gz": {"openeuler/arm64", "ubuntu/arm64"},
+	}
+	tests := []struct {
+		name     string
+		yaml     string
+		osFamily string
+		arch     string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Single path", yaml: `resources.tar.gz`, osFamily: OSFamilyFedora, arch: "arm64", want: "resources.tar.gz"},
+		{name: "List matched by manifest", yaml: `[ubuntu-amd64.tar.gz, openeuler-arm64.tar.gz]`, osFamily: OSFamilyUbuntu, arch: "arm64", want: "openeuler-arm64.tar.gz"},
+		{name: "List without match", yaml: `[ubuntu-amd64.tar.gz, openeuler-arm64.tar.gz]`, osFamily: OSFamilyFedora, arch: "amd64", wantErr: true},
+		{name: "Map prefers os family and arch", yaml: "amd64: any-amd64.tar.gz\nubuntu/amd64: ubuntu-amd64.tar.gz\nubuntu: ubuntu.tar.gz", osFamily: OSFamilyUbuntu, arch: "amd64", want: "ubuntu-amd64.tar.gz"},
+		{name: "Map prefers arch over os family", yaml: "arm64: any-arm64.tar.gz\nubuntu: ubuntu.tar.gz", osFamily: OSFamilyUbuntu, arch: "arm64", want: "any-arm64.tar.gz"},
+		{name: "Map falls back to os family", yaml: "arm64: any-arm64.tar.gz\nubuntu: ubuntu.tar.gz", osFamily: OSFamilyUbuntu, arch: "amd64", want: "ubuntu.tar.gz"},
+		{name: "Map without match", yaml: "openeuler/arm64: openeuler-arm64.tar.gz", osFamily: OSFamilyUbuntu, arch: "arm64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var packages ResourcePackages
+			if err := yaml.Unmarshal([]byte(tt.yaml), &packages); err != nil {
+				t.Fatal(err)
+			}
+			got, err := packages.Select(tt.osFamily, tt.arch, func(path string) (bool, error) {
+				return slices.Contains(manifests[path], tt.osFamily+"/"+tt.arch), nil
+			})
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Select() = %q, %v; want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	var packages ResourcePackages
+	if err := yaml.Unmarshal([]byte("debian/amd64: debian.tar.gz"), &packages); err == nil {
+		t.Error("unsupported os family key should fail")
+	}
+}
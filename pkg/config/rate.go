@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var byteUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+}
+
+// ParseByteRate 解析 "200MB/s"、"512KiB"、"1G" 形式的速率为字节/秒，单位按 1024 进制，"/s" 可省略
+func ParseByteRate(s string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	text = strings.TrimSpace(strings.TrimSuffix(text, "/S"))
+	i := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(text)
+	}
+	value, err := strconv.ParseFloat(text[:i], 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	unit, ok := byteUnits[strings.TrimSpace(text[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid rate unit in %q", s)
+	}
+	return int64(value * float64(unit)), nil
+}
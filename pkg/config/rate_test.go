@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestParseByteRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "200MB/s", want: 200 << 20},
+		{in: "512KiB", want: 512 << 10},
+		{in: "1.5g/s", want: 3 << 29},
+		{in: "1048576", want: 1 << 20},
+		{in: "0MB/s", wantErr: true},
+		{in: "100Mbps", wantErr: true},
+		{in: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseByteRate(%q) = %d, %v, want %d (err %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
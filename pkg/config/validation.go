@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -15,57 +16,118 @@ func stringInSlice(str string, slice []string) bool {
 	return false
 }
 
-// ApplyDefaultsAndValidate applies default values and validates the configuration
+// ApplyDefaultsAndValidate applies default values and validates the configuration.
+// 软件版本依赖资源包中的版本目录，读取资源包后由 ApplyVersions 补齐与校验
 func ApplyDefaultsAndValidate(cfg *Config) error {
-	if cfg.ResourcePackage == "" {
+	if len(cfg.ResourcePackage) == 0 || slices.Contains(cfg.ResourcePackage.Paths(), "") {
 		return errors.New("Error: resource_package is required in config.yaml")
 	}
+	for _, path := range cfg.ResourcePackage.Paths() {
+		if IsMirrorURL(path) {
+			if err := checkMirrorURL(path); err != nil {
+				return fmt.Errorf("Error: %v", err)
+			}
+		}
+	}
+	if _, err := ParseTrustedKeys(cfg.TrustedKeys); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 	if len(cfg.Nodes) == 0 {
 		return errors.New("Error: No nodes defined in config.yaml")
 	}
 	if cfg.CommandTimeoutSeconds <= 0 {
 		cfg.CommandTimeoutSeconds = 600
 	}
+	if cfg.SSHKeepaliveSeconds == 0 {
+		cfg.SSHKeepaliveSeconds = 15
+	}
+	if cfg.SSHReconnectAttempts == 0 {
+		cfg.SSHReconnectAttempts = 3
+	}
 	if !stringInSlice(cfg.InstallMode, SupportedInstallModes) {
 		return fmt.Errorf("Error: install_mode %s is not supported.", cfg.InstallMode)
 	}
-
-	versions := []struct {
-		name      string
-		value     *string
-		supported []string
-	}{
-		{"DockerCE", &cfg.Versions.DockerCE, DockerCEVersions},
-		{"Containerd", &cfg.Versions.Containerd, ContainerdVersions},
-		{"Runc", &cfg.Versions.Runc, RuncVersions},
-		{"Nerdctl", &cfg.Versions.Nerdctl, NerdctlVersions},
-		{"Kubernetes", &cfg.Versions.K8s, K8sVersions},
-		{"Kube-OVN", &cfg.Addons.KubeOvn.Version, KubeOvnVersions},
-		{"Multus CNI", &cfg.Addons.MultusCNI.Version, MultusCNIVersions},
-		{"HAMI", &cfg.Addons.Hami.Version, HamiVersions},
-		{"Kube Prometheus Stack", &cfg.Addons.KubePrometheus.Version, KubePrometheusVersions},
+	if cfg.HostKeyPolicy == "" {
+		cfg.HostKeyPolicy = HostKeyPolicyTOFU
 	}
-
-	for _, v := range versions {
-		if *v.value == "" {
-			*v.value = v.supported[0]
-			continue
-		}
-		if !stringInSlice(*v.value, v.supported) {
-			return fmt.Errorf("Error: %s version %s is not supported.", v.name, *v.value)
+	if !stringInSlice(cfg.HostKeyPolicy, SupportedHostKeyPolicies) {
+		return fmt.Errorf("Error: host_key_policy %s is not supported.", cfg.HostKeyPolicy)
+	}
+	if cfg.RecordDir != "" && cfg.ReplayDir != "" {
+		return errors.New("Error: record_dir and replay cannot be used together.")
+	}
+	if cfg.Distribution.Mode == "" {
+		cfg.Distribution.Mode = DistributionModeDirect
+	}
+	if !stringInSlice(cfg.Distribution.Mode, SupportedDistributionModes) {
+		return fmt.Errorf("Error: distribution mode %s is not supported.", cfg.Distribution.Mode)
+	}
+	if cfg.Distribution.UploadMode == "" {
+		cfg.Distribution.UploadMode = UploadModeFile
+	}
+	if !stringInSlice(cfg.Distribution.UploadMode, SupportedUploadModes) {
+		return fmt.Errorf("Error: distribution upload_mode %s is not supported.", cfg.Distribution.UploadMode)
+	}
+	// 流式解压不在节点上保留资源包，无法作为 p2p 中继来源
+	if cfg.Distribution.UploadMode == UploadModeStream && cfg.Distribution.Mode == DistributionModeP2P {
+		return errors.New("Error: distribution upload_mode stream cannot be used with p2p mode.")
+	}
+	if cfg.Distribution.Seeds <= 0 {
+		cfg.Distribution.Seeds = 1
+	}
+	if cfg.Distribution.Fanout <= 0 {
+		cfg.Distribution.Fanout = 3
+	}
+	if cfg.Distribution.RelayPort == 0 {
+		cfg.Distribution.RelayPort = 18080
+	}
+	if cfg.Distribution.RelayPort < 0 || cfg.Distribution.RelayPort > 65535 {
+		return fmt.Errorf("Error: distribution relay_port %d is invalid.", cfg.Distribution.RelayPort)
+	}
+	if cfg.UploadRateLimit != "" {
+		if _, err := ParseByteRate(cfg.UploadRateLimit); err != nil {
+			return fmt.Errorf("Error: upload_rate_limit %s is invalid.", cfg.UploadRateLimit)
 		}
 	}
+	if cfg.MaxConcurrentUploads < 0 {
+		return fmt.Errorf("Error: max_concurrent_uploads %d is invalid.", cfg.MaxConcurrentUploads)
+	}
 
+	localCount := 0
 	for i, node := range cfg.Nodes {
 		if strings.TrimSpace(node.IP) == "" {
 			return fmt.Errorf("Error: Node[%d] ip is required.", i)
 		}
-		if strings.TrimSpace(node.Password) == "" {
-			return fmt.Errorf("Error: Node[%d] password is required.", i)
-		}
 		if !node.IsMaster {
 			cfg.Nodes[i].IsPrimaryMaster = false
 		}
+		// 本机节点不经过 SSH，无需认证与跳板机
+		if node.Local {
+			localCount++
+			if localCount > 1 {
+				return fmt.Errorf("Error: Node[%d] only one node can be local.", i)
+			}
+			continue
+		}
+		// 回放时不连接节点
+		if cfg.ReplayDir != "" {
+			continue
+		}
+		auth := cfg.NodeAuth(&cfg.Nodes[i])
+		if auth.IsEmpty() {
+			return fmt.Errorf("Error: Node[%d] requires at least one ssh auth method (password, private_key, ssh_agent or keyboard_interactive).", i)
+		}
+		if auth.KeyboardInteractive && strings.TrimSpace(auth.Password) == "" {
+			return fmt.Errorf("Error: Node[%d] keyboard_interactive requires password.", i)
+		}
+		for j, bastion := range cfg.NodeBastions(&cfg.Nodes[i]) {
+			if strings.TrimSpace(bastion.Host) == "" {
+				return fmt.Errorf("Error: Node[%d] bastion[%d] host is required.", i, j)
+			}
+			if cfg.BastionAuth(&bastion).IsEmpty() {
+				return fmt.Errorf("Error: Node[%d] bastion[%d] requires at least one ssh auth method.", i, j)
+			}
+		}
 	}
 
 	hasMaster := false
@@ -20,7 +20,7 @@ func TestApplyDefaultsAndValidate(t *testing.T) {
 		{
 			name: "Valid basic config",
 			cfg: &Config{
-				ResourcePackage: "./resources.tar.gz",
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
 				Nodes: []NodeConfig{
 					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
 				},
@@ -31,7 +31,7 @@ func TestApplyDefaultsAndValidate(t *testing.T) {
 		{
 			name: "Missing node IP",
 			cfg: &Config{
-				ResourcePackage: "./resources.tar.gz",
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
 				Nodes: []NodeConfig{
 					{IP: "", Password: "pass", IsMaster: true},
 				},
@@ -39,10 +39,198 @@ func TestApplyDefaultsAndValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Missing node auth",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Node private key auth",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", PrivateKey: "~/.ssh/id_ed25519", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Global ssh agent auth",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				SSHAgent:        true,
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Keyboard interactive without password",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", KeyboardInteractive: true, IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported host key policy",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				HostKeyPolicy:   "trust-all",
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Local node without ssh auth",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Local: true, IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Multiple local nodes",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Local: true, IsMaster: true},
+					{IP: "192.168.1.2", Local: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported distribution mode",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Distribution:    DistributionConfig{Mode: "bittorrent"},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Stream upload with p2p",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Distribution:    DistributionConfig{Mode: DistributionModeP2P, UploadMode: UploadModeStream},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Replay without ssh auth",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				ReplayDir:       "./transcripts",
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Record and replay together",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				RecordDir:       "./transcripts",
+				ReplayDir:       "./transcripts",
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid trusted key",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				TrustedKeys:     []string{"ssh-rsa not-a-key"},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Mirror url with query",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "http://10.0.0.5:8080/resources?token=1"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid upload rate limit",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				UploadRateLimit: "fast",
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Bastion without host",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Bastion:         []BastionConfig{{User: "jump"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Node overrides bastion with direct connection",
+			cfg: &Config{
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
+				Bastion:         []BastionConfig{{User: "jump"}},
+				Nodes: []NodeConfig{
+					{IP: "192.168.1.1", Password: "pass", IsMaster: true, Bastion: []BastionConfig{}},
+				},
+				InstallMode: InstallModeFull,
+			},
+			wantErr: false,
+		},
 		{
 			name: "Invalid HA config (less than 3 masters)",
 			cfg: &Config{
-				ResourcePackage: "./resources.tar.gz",
+				ResourcePackage: ResourcePackages{{Path: "./resources.tar.gz"}},
 				Nodes: []NodeConfig{
 					{IP: "192.168.1.1", Password: "pass", IsMaster: true, IsPrimaryMaster: true, Interface: "eth0"},
 				},
@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Executor 在目标节点上执行命令与传输文件，SSH、本机与测试替身均实现该接口
+type Executor interface {
+	// Run 执行命令并返回结果；退出码非 0 时返回 *ExitError，
+	// 命令未能启动时 Result 为 nil
+	Run(ctx context.Context, cmd string) (*Result, error)
+	// Stream 与 Run 语义一致，执行期间将合并输出逐行回调给 onLine
+	Stream(ctx context.Context, cmd string, onLine func(line string)) (*Result, error)
+	// RunStdin 执行命令并将 stdin 写入其标准输入，用于向远端流式传输数据；
+	// 耗时取决于数据量，不受命令超时限制，数据开始传输后连接断开不会重试
+	RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*Result, error)
+	// PutFile 将 src 写入目标路径，total 为总字节数（未知时为 0）；
+	// 经网络传输的实现按 ctx 中 WithRateLimit 设置的限速器限速
+	PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(UploadProgress)) error
+	// GetFile 读取目标路径的文件写入 dst
+	GetFile(ctx context.Context, remotePath string, dst io.Writer) error
+	Close()
+}
+
+// UploadProgress 上传进度；Resumed 为本次断点续传的起始偏移，从头上传时为 0
+type UploadProgress struct {
+	Current int64
+	Total   int64
+	Resumed int64
+}
+
+type retryKey struct{}
+
+// WithRetry 标记 ctx 下的命令可重复执行：若执行中途连接断开，重连后会自动重跑。
+// 仅用于只读探测等幂等命令；未标记的命令只在会话尚未建立时重试。
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// Retryable 报告 ctx 是否经 WithRetry 标记
+func Retryable(ctx context.Context) bool {
+	v, _ := ctx.Value(retryKey{}).(bool)
+	return v
+}
+
+// DetectArch 检测目标节点架构
+func DetectArch(ctx context.Context, e Executor) (string, error) {
+	res, err := e.Run(WithRetry(ctx), "uname -m")
+	if err != nil {
+		return "", err
+	}
+	out := res.Stdout
+	if strings.Contains(out, "x86_64") {
+		return "amd64", nil
+	}
+	if strings.Contains(out, "aarch64") {
+		return "arm64", nil
+	}
+	return strings.TrimSpace(out), nil
+}
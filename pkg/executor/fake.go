@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var _ Executor = (*Fake)(nil)
+
+// Fake 按脚本应答命令的测试替身，记录执行过的命令与写入的文件
+type Fake struct {
+	mu       sync.Mutex
+	rules    []fakeRule
+	commands []string
+	files    map[string][]byte
+	inputs   map[string][]byte
+}
+
+type fakeRule struct {
+	match    string
+	stdout   string
+	exitCode int
+	err      error
+}
+
+func NewFake() *Fake {
+	return &Fake{files: make(map[string][]byte), inputs: make(map[string][]byte)}
+}
+
+// On 命令包含 match 时输出 stdout 并以 exitCode 退出；按添加顺序匹配第一条，
+// 未匹配的命令输出为空、退出码为 0
+func (f *Fake) On(match, stdout string, exitCode int) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{match: match, stdout: stdout, exitCode: exitCode})
+	return f
+}
+
+// OnError 命令包含 match 时模拟无法执行（如连接断开），返回 err
+func (f *Fake) OnError(match string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{match: match, exitCode: -1, err: err})
+	return f
+}
+
+// Commands 返回已执行的命令
+func (f *Fake) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// File 返回经 PutFile 写入的文件内容
+func (f *Fake) File(remotePath string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.files[remotePath]
+	return data, ok
+}
+
+// SetFile 预置 GetFile 可读取的文件
+func (f *Fake) SetFile(remotePath string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[remotePath] = data
+}
+
+func (f *Fake) Run(ctx context.Context, cmd string) (*Result, error) {
+	return f.Stream(ctx, cmd, nil)
+}
+
+func (f *Fake) Stream(ctx context.Context, cmd string, onLine func(line string)) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("command '%s' cancelled: %w", cmd, err)
+	}
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	rule := fakeRule{}
+	for i := range f.rules {
+		if strings.Contains(cmd, f.rules[i].match) {
+			rule = f.rules[i]
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	capture := NewCapture(onLine)
+	capture.Stdout().Write([]byte(rule.stdout))
+	res := capture.Result(cmd, rule.exitCode)
+	if rule.err != nil {
+		return res, fmt.Errorf("command '%s' failed: %w", cmd, rule.err)
+	}
+	if rule.exitCode != 0 {
+		return res, &ExitError{Result: res}
+	}
+	return res, nil
+}
+
+// Input 返回经 RunStdin 写入命令的标准输入
+func (f *Fake) Input(cmd string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inputs[cmd]
+}
+
+// RunStdin 读完 stdin 后按规则应答
+func (f *Fake) RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*Result, error) {
+	data, err := io.ReadAll(&ContextReader{Ctx: ctx, R: stdin})
+	if err != nil {
+		return nil, fmt.Errorf("command '%s' failed: %w", cmd, err)
+	}
+	f.mu.Lock()
+	f.inputs[cmd] = data
+	f.mu.Unlock()
+	return f.Stream(ctx, cmd, nil)
+}
+
+func (f *Fake) PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(UploadProgress)) error {
+	data, err := io.ReadAll(&ContextReader{Ctx: ctx, R: src})
+	if err != nil {
+		return err
+	}
+	if onProgress != nil {
+		onProgress(UploadProgress{Current: int64(len(data)), Total: total})
+	}
+	f.SetFile(remotePath, data)
+	return nil
+}
+
+func (f *Fake) GetFile(ctx context.Context, remotePath string, dst io.Writer) error {
+	data, ok := f.File(remotePath)
+	if !ok {
+		return fmt.Errorf("open %s: %w", remotePath, os.ErrNotExist)
+	}
+	_, err := io.Copy(dst, bytes.NewReader(data))
+	return err
+}
+
+func (f *Fake) Close() {}
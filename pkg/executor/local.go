@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+var _ Executor = (*Local)(nil)
+
+// Local 直接在运行工具的机器上执行命令，用于单节点边缘设备等无需 SSH 的场景
+type Local struct {
+	timeout time.Duration
+}
+
+// NewLocal 本机模式下命令不经过 sudo，要求以 root 运行
+func NewLocal(timeout time.Duration) (*Local, error) {
+	if os.Geteuid() != 0 {
+		return nil, errors.New("local executor requires running as root")
+	}
+	return &Local{timeout: timeout}, nil
+}
+
+func (l *Local) Run(ctx context.Context, cmd string) (*Result, error) {
+	return l.Stream(ctx, cmd, nil)
+}
+
+// Stream 通过 bash -c 执行命令，ctx 取消时先发送 SIGTERM，10 秒后仍未退出则强制结束
+func (l *Local) Stream(ctx context.Context, cmd string, onLine func(line string)) (*Result, error) {
+	return l.run(ctx, cmd, nil, onLine, l.timeout)
+}
+
+func (l *Local) RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*Result, error) {
+	return l.run(ctx, cmd, &ContextReader{Ctx: ctx, R: stdin}, nil, 0)
+}
+
+func (l *Local) run(ctx context.Context, cmd string, stdin io.Reader, onLine func(line string), timeout time.Duration) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("command '%s' cancelled: %w", cmd, err)
+	}
+
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(runCtx, "bash", "-c", cmd)
+	c.Cancel = func() error { return c.Process.Signal(syscall.SIGTERM) }
+	c.WaitDelay = 10 * time.Second
+	c.Stdin = stdin
+	capture := NewCapture(onLine)
+	c.Stdout = capture.Stdout()
+	c.Stderr = capture.Stderr()
+
+	err := c.Run()
+	if err == nil {
+		return capture.Result(cmd, 0), nil
+	}
+	if ctx.Err() != nil {
+		return capture.Result(cmd, -1), fmt.Errorf("command '%s' cancelled: %w", cmd, ctx.Err())
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return capture.Result(cmd, -1), fmt.Errorf("command '%s' timed out after %s", cmd, timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		res := capture.Result(cmd, exitErr.ExitCode())
+		return res, &ExitError{Result: res}
+	}
+	res := capture.Result(cmd, -1)
+	return res, fmt.Errorf("command '%s' failed: %v, output: %s", cmd, err, res.Text())
+}
+
+func (l *Local) PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(UploadProgress)) error {
+	if err := os.MkdirAll(filepath.Dir(remotePath), 0755); err != nil {
+		return fmt.Errorf("mkdir -p %s failed: %v", filepath.Dir(remotePath), err)
+	}
+	f, err := os.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("create file %s failed: %v", remotePath, err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = &ContextReader{Ctx: ctx, R: src}
+	if onProgress != nil {
+		reader = &ProgressReader{
+			Reader: reader,
+			Total:  total,
+			OnProgress: func(current, total int64) {
+				onProgress(UploadProgress{Current: current, Total: total})
+			},
+		}
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		return fmt.Errorf("write %s failed: %v", remotePath, err)
+	}
+	return f.Close()
+}
+
+func (l *Local) GetFile(ctx context.Context, remotePath string, dst io.Writer) error {
+	f, err := os.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(dst, &ContextReader{Ctx: ctx, R: f})
+	return err
+}
+
+func (l *Local) Close() {}
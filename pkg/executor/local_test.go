@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalExecutor(t *testing.T) {
+	l := &Local{timeout: 5 * time.Second}
+	ctx := context.Background()
+
+	var lines []string
+	res, err := l.Stream(ctx, "echo first; echo second >&2", func(line string) { lines = append(lines, line) })
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if res.Stdout != "first\n" || res.Stderr != "second\n" || res.Text() != "first\nsecond" || len(lines) != 2 {
+		t.Errorf("Stream() = %+v, lines %q", res, lines)
+	}
+
+	res, err = l.Run(ctx, "exit 3")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || res.ExitCode != 3 {
+		t.Errorf("Run(exit 3) = %+v, %v", res, err)
+	}
+	if res, err := IgnoreExit(l.Run(ctx, "missing-binary-xyz")); err != nil || res.ExitCode != 127 {
+		t.Errorf("IgnoreExit(missing binary) = %+v, %v", res, err)
+	}
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := l.Run(cancelCtx, "sleep 5"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run(cancelled) error = %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "sub", "file.txt")
+	var progress UploadProgress
+	if err := l.PutFile(ctx, dst, strings.NewReader("payload"), 7, func(p UploadProgress) { progress = p }); err != nil {
+		t.Fatalf("PutFile() error = %v", err)
+	}
+	if progress.Current != 7 {
+		t.Errorf("progress = %+v", progress)
+	}
+	var buf bytes.Buffer
+	if err := l.GetFile(ctx, dst, &buf); err != nil || buf.String() != "payload" {
+		t.Errorf("GetFile() = %q, %v", buf.String(), err)
+	}
+}
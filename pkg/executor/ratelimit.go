@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// limiterChunk 单次读取的最大字节数，使多个上传能交替获得令牌
+const limiterChunk = 32 * 1024
+
+// Limiter 令牌桶限速器，多个传输共享同一个 Limiter 时分摊总带宽
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // 字节/秒
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter 创建每秒 bytesPerSec 字节的限速器，允许约 100ms 的突发
+func NewLimiter(bytesPerSec int64) *Limiter {
+	burst := max(float64(bytesPerSec)/10, limiterChunk)
+	return &Limiter{rate: float64(bytesPerSec), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// WaitN 预留 n 字节的令牌并等待到可发送的时刻；令牌可预支为负数，后来者排在其后
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type limiterKey struct{}
+
+// WithRateLimit 为 ctx 下的文件上传设置共享限速器
+func WithRateLimit(ctx context.Context, l *Limiter) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// RateLimited ctx 设置了限速器时返回限速的 Reader，否则原样返回
+func RateLimited(ctx context.Context, r io.Reader) io.Reader {
+	l, ok := ctx.Value(limiterKey{}).(*Limiter)
+	if !ok {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > limiterChunk {
+		p = p[:limiterChunk]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if werr := lr.limiter.WaitN(lr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
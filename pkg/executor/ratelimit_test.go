@@ -0,0 +1,32 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterSharedAcrossReaders(t *testing.T) {
+	// 320KB/s 共享给两个读取者，各读 96KB，扣除 32KB 突发后约需 0.5s
+	ctx := WithRateLimit(context.Background(), NewLimiter(320*1024))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(io.Discard, RateLimited(ctx, bytes.NewReader(make([]byte, 96*1024))))
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("limited copy took %v, want about 500ms", elapsed)
+	}
+
+	if r := bytes.NewReader(nil); RateLimited(context.Background(), r) != r {
+		t.Error("RateLimited without limiter should return the reader unchanged")
+	}
+}
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"context"
+	"io"
+)
+
+// ProgressReader wraps an io.Reader to track progress
+type ProgressReader struct {
+	Reader     io.Reader
+	Total      int64
+	Current    int64
+	OnProgress func(current, total int64)
+}
+
+func (pr *ProgressReader) Read(p []byte) (n int, err error) {
+	n, err = pr.Reader.Read(p)
+	pr.Current += int64(n)
+	if pr.OnProgress != nil {
+		pr.OnProgress(pr.Current, pr.Total)
+	}
+	return
+}
+
+// ContextReader 在 ctx 取消后中断读取，用于终止正在进行的上传
+type ContextReader struct {
+	Ctx context.Context
+	R   io.Reader
+}
+
+func (cr *ContextReader) Read(p []byte) (int, error) {
+	if err := cr.Ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.R.Read(p)
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result 一次命令执行的结果
+type Result struct {
+	Command  string
+	Stdout   string
+	Stderr   string
+	Output   string // Stdout 与 Stderr 按到达顺序合并
+	ExitCode int    // 命令未正常结束（取消、超时、连接断开）时为 -1
+	Duration time.Duration
+}
+
+// Text 返回去除首尾空白的合并输出
+func (r *Result) Text() string {
+	if r == nil {
+		return ""
+	}
+	return strings.TrimSpace(r.Output)
+}
+
+// ExitError 命令已执行完毕但退出码非 0
+type ExitError struct {
+	Result *Result
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command '%s' failed: exit status %d, output: %s", e.Result.Command, e.Result.ExitCode, e.Result.Text())
+}
+
+// Output 将 Run/Stream 的返回值转换为合并输出：成功时去除首尾空白，失败时保留原始输出
+func Output(res *Result, err error) (string, error) {
+	if err != nil {
+		if res == nil {
+			return "", err
+		}
+		return res.Output, err
+	}
+	return res.Text(), nil
+}
+
+// IgnoreExit 将非 0 退出码视为正常结果，仅保留无法执行（取消、超时、连接断开）的错误，
+// 供只读检查根据 ExitCode 自行判断
+func IgnoreExit(res *Result, err error) (*Result, error) {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Result, nil
+	}
+	return res, err
+}
+
+// Capture 分别收集 Stdout/Stderr，同时按到达顺序合并并逐行回调
+type Capture struct {
+	stdout   lockedBuffer
+	stderr   lockedBuffer
+	combined *LineWriter
+	start    time.Time
+}
+
+func NewCapture(onLine func(line string)) *Capture {
+	return &Capture{combined: NewLineWriter(onLine), start: time.Now()}
+}
+
+func (c *Capture) Stdout() io.Writer { return io.MultiWriter(&c.stdout, c.combined) }
+func (c *Capture) Stderr() io.Writer { return io.MultiWriter(&c.stderr, c.combined) }
+
+// Result 结束收集并生成结果
+func (c *Capture) Result(cmd string, exitCode int) *Result {
+	c.combined.Flush()
+	return &Result{
+		Command:  cmd,
+		Stdout:   c.stdout.String(),
+		Stderr:   c.stderr.String(),
+		Output:   c.combined.String(),
+		ExitCode: exitCode,
+		Duration: time.Since(c.start),
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
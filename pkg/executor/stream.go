@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// LineWriter 汇总命令的完整输出，同时按行回调（Stdout 与 Stderr 共用，需加锁）
+type LineWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	partial []byte
+	onLine  func(line string)
+}
+
+func NewLineWriter(onLine func(line string)) *LineWriter {
+	return &LineWriter{onLine: onLine}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	if w.onLine == nil {
+		return len(p), nil
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.onLine(strings.TrimRight(string(w.partial[:idx]), "\r"))
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 回调最后一行不以换行结尾的输出
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.onLine != nil && len(w.partial) > 0 {
+		w.onLine(strings.TrimRight(string(w.partial), "\r"))
+	}
+	w.partial = nil
+}
+
+func (w *LineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
@@ -0,0 +1,29 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := NewLineWriter(func(line string) { lines = append(lines, line) })
+
+	w.Write([]byte("[init] Using Kubernetes"))
+	w.Write([]byte(" version: v1.34.4\r\n[preflight] Running"))
+	w.Write([]byte(" pre-flight checks\n\nYour Kubernetes control-plane"))
+	w.Flush()
+
+	want := []string{
+		"[init] Using Kubernetes version: v1.34.4",
+		"[preflight] Running pre-flight checks",
+		"",
+		"Your Kubernetes control-plane",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if got := w.String(); got != "[init] Using Kubernetes version: v1.34.4\r\n[preflight] Running pre-flight checks\n\nYour Kubernetes control-plane" {
+		t.Errorf("String() = %q", got)
+	}
+}
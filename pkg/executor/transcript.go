@@ -0,0 +1,303 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpRun = "run"
+	OpPut = "put"
+	OpGet = "get"
+)
+
+// Entry 会话记录中的一行（JSON Lines），对应一次命令执行或文件传输
+type Entry struct {
+	Op         string    `json:"op"`
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Stdout     string    `json:"stdout,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMs int64     `json:"duration_ms"`
+	NotStarted bool      `json:"not_started,omitempty"` // 命令未能启动，Result 为 nil
+	Error      string    `json:"error,omitempty"`       // 非退出码导致的失败（取消、超时、连接断开等）
+	Size       int64     `json:"size,omitempty"`        // 上传或写入标准输入的字节数
+	Data       []byte    `json:"data,omitempty"`        // GetFile 读取到的内容
+}
+
+var _ Executor = (*Recorder)(nil)
+
+// Recorder 包装任意 Executor，将每次执行的命令、输出与退出码追加写入会话记录
+type Recorder struct {
+	exec Executor
+
+	mu  sync.Mutex
+	out *os.File
+}
+
+// openedTranscripts 本进程已打开过的会话记录文件
+var (
+	openedMu          sync.Mutex
+	openedTranscripts = make(map[string]bool)
+)
+
+// NewRecorder 打开会话记录文件：本进程首次打开时清空上次运行的记录，之后以追加方式写入，
+// 同一节点的多个连接可写入同一文件
+func NewRecorder(exec Executor, path string) (*Recorder, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	openedMu.Lock()
+	defer openedMu.Unlock()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !openedTranscripts[abs] {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript %s: %v", path, err)
+	}
+	openedTranscripts[abs] = true
+	return &Recorder{exec: exec, out: f}, nil
+}
+
+func (r *Recorder) record(entry Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 整行一次写入，多个 Recorder 追加同一文件时不会交错
+	r.out.Write(append(data, '\n'))
+}
+
+// recordResult 补全命令执行结果后写入记录
+func (r *Recorder) recordResult(entry Entry, res *Result, err error) {
+	entry.ExitCode = -1
+	entry.DurationMs = time.Since(entry.Time).Milliseconds()
+	if res == nil {
+		entry.NotStarted = true
+	} else {
+		entry.Stdout, entry.Stderr, entry.Output, entry.ExitCode = res.Stdout, res.Stderr, res.Output, res.ExitCode
+	}
+	var exitErr *ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		entry.Error = err.Error()
+	}
+	r.record(entry)
+}
+
+func (r *Recorder) Run(ctx context.Context, cmd string) (*Result, error) {
+	start := time.Now()
+	res, err := r.exec.Run(ctx, cmd)
+	r.recordResult(Entry{Op: OpRun, Time: start, Command: cmd}, res, err)
+	return res, err
+}
+
+func (r *Recorder) Stream(ctx context.Context, cmd string, onLine func(line string)) (*Result, error) {
+	start := time.Now()
+	res, err := r.exec.Stream(ctx, cmd, onLine)
+	r.recordResult(Entry{Op: OpRun, Time: start, Command: cmd}, res, err)
+	return res, err
+}
+
+func (r *Recorder) RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*Result, error) {
+	start := time.Now()
+	counter := &countingReader{r: stdin}
+	res, err := r.exec.RunStdin(ctx, cmd, counter)
+	r.recordResult(Entry{Op: OpRun, Time: start, Command: cmd, Size: counter.n}, res, err)
+	return res, err
+}
+
+func (r *Recorder) PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(UploadProgress)) error {
+	start := time.Now()
+	counter := &countingReader{r: src}
+	err := r.exec.PutFile(ctx, remotePath, counter, total, onProgress)
+	entry := Entry{Op: OpPut, Time: start, Path: remotePath, Size: counter.n, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.record(entry)
+	return err
+}
+
+func (r *Recorder) GetFile(ctx context.Context, remotePath string, dst io.Writer) error {
+	start := time.Now()
+	var buf bytes.Buffer
+	err := r.exec.GetFile(ctx, remotePath, io.MultiWriter(dst, &buf))
+	entry := Entry{Op: OpGet, Time: start, Path: remotePath, Data: buf.Bytes(), DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.record(entry)
+	return err
+}
+
+func (r *Recorder) Close() {
+	r.exec.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+var _ Executor = (*Replay)(nil)
+
+// ErrNotRecorded 回放时遇到会话记录中没有的命令或文件操作
+var ErrNotRecorded = errors.New("not found in transcript")
+
+// Replay 按会话记录应答命令，不连接任何节点。
+// 同一命令按记录顺序依次返回，次数超出记录时重复最后一次结果；记录中没有的操作返回 ErrNotRecorded。
+type Replay struct {
+	mu      sync.Mutex
+	entries map[string][]Entry
+	used    map[string]int
+}
+
+// LoadReplay 读取 Recorder 生成的会话记录
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := &Replay{entries: make(map[string][]Entry), used: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid transcript %s line %d: %v", path, line, err)
+		}
+		key := replayKey(entry.Op, entry.Command+entry.Path)
+		r.entries[key] = append(r.entries[key], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript %s: %v", path, err)
+	}
+	return r, nil
+}
+
+func replayKey(op, target string) string {
+	return op + "\x00" + target
+}
+
+func (r *Replay) next(op, target string) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := replayKey(op, target)
+	entries := r.entries[key]
+	if len(entries) == 0 {
+		return Entry{}, fmt.Errorf("%s '%s': %w", op, target, ErrNotRecorded)
+	}
+	i := min(r.used[key], len(entries)-1)
+	r.used[key]++
+	return entries[i], nil
+}
+
+func (r *Replay) Run(ctx context.Context, cmd string) (*Result, error) {
+	return r.Stream(ctx, cmd, nil)
+}
+
+func (r *Replay) Stream(ctx context.Context, cmd string, onLine func(line string)) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("command '%s' cancelled: %w", cmd, err)
+	}
+	entry, err := r.next(OpRun, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if entry.NotStarted {
+		return nil, errors.New(entry.Error)
+	}
+	if onLine != nil {
+		for _, line := range strings.SplitAfter(entry.Output, "\n") {
+			if line = strings.TrimRight(line, "\r\n"); line != "" {
+				onLine(line)
+			}
+		}
+	}
+	res := &Result{
+		Command:  cmd,
+		Stdout:   entry.Stdout,
+		Stderr:   entry.Stderr,
+		Output:   entry.Output,
+		ExitCode: entry.ExitCode,
+		Duration: time.Duration(entry.DurationMs) * time.Millisecond,
+	}
+	if entry.Error != "" {
+		return res, errors.New(entry.Error)
+	}
+	if res.ExitCode != 0 {
+		return res, &ExitError{Result: res}
+	}
+	return res, nil
+}
+
+// RunStdin 读完 stdin 后按记录应答，调用方对已发送数据的校验与现场一致
+func (r *Replay) RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*Result, error) {
+	if _, err := io.Copy(io.Discard, &ContextReader{Ctx: ctx, R: stdin}); err != nil {
+		return nil, fmt.Errorf("command '%s' failed: %w", cmd, err)
+	}
+	return r.Stream(ctx, cmd, nil)
+}
+
+// PutFile 不读取 src，直接按记录返回上传结果
+func (r *Replay) PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(UploadProgress)) error {
+	entry, err := r.next(OpPut, remotePath)
+	if err != nil {
+		return err
+	}
+	if entry.Error != "" {
+		return errors.New(entry.Error)
+	}
+	if onProgress != nil {
+		onProgress(UploadProgress{Current: entry.Size, Total: entry.Size})
+	}
+	return nil
+}
+
+func (r *Replay) GetFile(ctx context.Context, remotePath string, dst io.Writer) error {
+	entry, err := r.next(OpGet, remotePath)
+	if err != nil {
+		return err
+	}
+	if entry.Error != "" {
+		return errors.New(entry.Error)
+	}
+	_, err = dst.Write(entry.Data)
+	return err
+}
+
+func (r *Replay) Close() {}
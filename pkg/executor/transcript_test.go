@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "10.0.0.1.jsonl")
+	fake := NewFake().
+		On("uname -m", "x86_64\n", 0).
+		On("systemctl is-active containerd", "inactive\n", 3).
+		OnError("kubeadm init", errors.New("connection lost"))
+	fake.SetFile("/etc/os-release", []byte("NAME=Ubuntu\n"))
+
+	rec, err := NewRecorder(fake, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Run(ctx, "uname -m")
+	rec.Run(ctx, "systemctl is-active containerd")
+	rec.Stream(ctx, "kubeadm init", nil)
+	rec.PutFile(ctx, "/tmp/pkg.tar.gz", strings.NewReader("payload"), 7, nil)
+	rec.GetFile(ctx, "/etc/os-release", &bytes.Buffer{})
+	rec.Close()
+
+	replay, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := replay.Run(ctx, "uname -m"); err != nil || res.Stdout != "x86_64\n" {
+		t.Errorf("uname -m = %+v, %v", res, err)
+	}
+	// 同一命令次数超出记录时重复最后一次结果
+	if res, err := replay.Run(ctx, "uname -m"); err != nil || res.Stdout != "x86_64\n" {
+		t.Errorf("second uname -m = %+v, %v", res, err)
+	}
+
+	var exitErr *ExitError
+	if _, err := replay.Run(ctx, "systemctl is-active containerd"); !errors.As(err, &exitErr) || exitErr.Result.ExitCode != 3 {
+		t.Errorf("systemctl err = %v, want exit status 3", err)
+	}
+
+	var lines []string
+	res, err := replay.Stream(ctx, "kubeadm init", func(line string) { lines = append(lines, line) })
+	if err == nil || !strings.Contains(err.Error(), "connection lost") || errors.As(err, &exitErr) || res.ExitCode != -1 {
+		t.Errorf("kubeadm init = %+v, %v, want recorded transport error", res, err)
+	}
+
+	var progress UploadProgress
+	if err := replay.PutFile(ctx, "/tmp/pkg.tar.gz", nil, 7, func(p UploadProgress) { progress = p }); err != nil || progress.Current != 7 {
+		t.Errorf("PutFile = %v, progress %+v", err, progress)
+	}
+
+	var buf bytes.Buffer
+	if err := replay.GetFile(ctx, "/etc/os-release", &buf); err != nil || buf.String() != "NAME=Ubuntu\n" {
+		t.Errorf("GetFile = %q, %v", buf.String(), err)
+	}
+
+	if _, err := replay.Run(ctx, "rm -rf /"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded command err = %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestRecorderStartsNewRun(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "10.0.0.1.jsonl")
+	record := func(out string) {
+		rec, err := NewRecorder(NewFake().On("hostname", out, 0), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.Run(ctx, "hostname")
+		rec.Close()
+	}
+
+	// 同一进程内的多个连接追加到同一文件
+	record("run1\n")
+	record("run1-reconnect\n")
+	replay, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := replay.Run(ctx, "hostname"); res == nil || res.Stdout != "run1\n" {
+		t.Fatalf("first hostname = %+v", res)
+	}
+	if res, _ := replay.Run(ctx, "hostname"); res == nil || res.Stdout != "run1-reconnect\n" {
+		t.Fatalf("second hostname = %+v", res)
+	}
+
+	// 新进程的记录覆盖上次运行
+	abs, _ := filepath.Abs(path)
+	openedMu.Lock()
+	delete(openedTranscripts, abs)
+	openedMu.Unlock()
+	record("run2\n")
+	if replay, err = LoadReplay(path); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := replay.Run(ctx, "hostname"); res == nil || res.Stdout != "run2\n" {
+		t.Errorf("hostname after new run = %+v, want run2", res)
+	}
+}
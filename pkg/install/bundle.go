@@ -0,0 +1,440 @@
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-offline-tool/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// layoutRule 资源包中一类文件的路径约定，与各安装步骤拼接的路径保持一致。
+// 命名分组：arch 目录架构，farch 文件名中的架构，pkg 包格式目录，ext 包扩展名，
+// dir 以 "-" 分隔的版本目录，version 文件名中的版本，chart chart 版本
+type layoutRule struct {
+	re      *regexp.Regexp
+	version string // 记录到 manifest versions 的键
+	chart   string // 记录到 manifest charts 的名称
+	values  bool   // chart 目录需要 values.yaml
+}
+
+const (
+	archPattern = `(?P<arch>amd64|arm64)`
+	pkgPattern  = `(?P<pkg>apt|rpm)/[^/]+\.(?P<ext>deb|rpm)`
+)
+
+var layoutRules = []layoutRule{
+	{re: regexp.MustCompile(`^docker-ce/docker/` + archPattern + `/(?P<dir>[^/]+)/docker-(?P<version>[^/]+)\.tgz$`), version: "dockerce"},
+	{re: regexp.MustCompile(`^docker-ce/containerd/` + archPattern + `/(?P<dir>[^/]+)/containerd-(?P<version>[^/]+)-linux-(?P<farch>[^/-]+)\.tar\.gz$`), version: "containerd"},
+	{re: regexp.MustCompile(`^docker-ce/runc/` + archPattern + `/(?P<dir>[^/]+)/runc\.(?P<farch>[^/]+)$`), version: "runc"},
+	{re: regexp.MustCompile(`^nerdctl/` + archPattern + `/(?P<dir>[^/]+)/nerdctl-(?P<version>[^/]+)-linux-(?P<farch>[^/-]+)\.tar\.gz$`), version: "nerdctl"},
+	{re: regexp.MustCompile(`^k8s/` + archPattern + `/(?P<pkg>apt|rpm)/(?P<dir>[^/]+)/[^/]+\.(?P<ext>deb|rpm)$`), version: "k8s"},
+	{re: regexp.MustCompile(`^common-tools/` + archPattern + `/` + pkgPattern + `$`)},
+	{re: regexp.MustCompile(`^ha/(haproxy|keepalived)/` + archPattern + `/` + pkgPattern + `$`)},
+	{re: regexp.MustCompile(`^helm/` + archPattern + `/helm-v[^/]+-linux-(?P<farch>[^/-]+)\.tar\.gz$`)},
+	{re: regexp.MustCompile(`^docker-runtime/ascend/` + archPattern + `/.+$`)},
+	{re: regexp.MustCompile(`^helm-resource/cni/kube-ovn/(kube-ovn-v(?P<chart>[^/]+)\.tgz|values\.yaml)$`), chart: "kube-ovn", values: true},
+	{re: regexp.MustCompile(`^helm-resource/kube-prometheus-stack/(kube-prometheus-stack-(?P<chart>[^/]+)\.tgz|values\.yaml)$`), chart: "kube-prometheus-stack", values: true},
+	{re: regexp.MustCompile(`^helm-resource/hami/hami/(hami-(?P<chart>[^/]+)\.tgz|values\.yaml)$`), chart: "hami", values: true},
+	{re: regexp.MustCompile(`^helm-resource/hami/hami-webui/(hami-webui-(?P<chart>[^/]+)\.tgz|values\.yaml)$`), chart: "hami-webui", values: true},
+	{re: regexp.MustCompile(`^helm-resource/hami/ascend-vnpu-device-plugin/[^/]+$`), chart: "ascend-vnpu-device-plugin"},
+	{re: regexp.MustCompile(`^cni/multus-cni/multus-daemonset-thick\.yml$`), chart: "multus-cni"},
+}
+
+// bundleImagesName 暂存目录根下可选的镜像分组文件，格式与内置 images.yaml 相同
+const bundleImagesName = "images.yaml"
+
+// multusImage multus 没有 chart 包，版本取 DaemonSet 中的镜像标签
+var multusImage = regexp.MustCompile(`multus-cni:(\S+)`)
+
+// BundleOptions bundle 子命令参数
+type BundleOptions struct {
+	Source string // 已下载资源的暂存目录
+	Output string // 生成的资源包路径
+	Name   string // 写入 manifest 的资源包名称
+	// 签名私钥路径（OpenSSH 格式 ed25519），为空时生成未签名的资源包
+	KeyFile string
+}
+
+// metaFile bundle 生成的 manifest.yaml 与签名
+type metaFile struct {
+	name string
+	data []byte
+}
+
+// bundleFile 暂存目录中的一个文件
+type bundleFile struct {
+	path string // 相对暂存目录的路径
+	mode fs.FileMode
+	size int64
+	hash string
+}
+
+// Bundle 校验暂存目录的目录结构，生成 manifest.yaml 并打包为内容确定的 tar.gz：
+// 条目按路径排序，时间戳、属主统一，相同输入得到字节相同的资源包
+func Bundle(opts BundleOptions) (*config.PackageManifest, error) {
+	files, dirs, err := scanStaging(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := buildManifest(opts.Name, opts.Source, files)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := yaml.Marshal(pm)
+	if err != nil {
+		return nil, err
+	}
+	meta := []metaFile{{config.PackageManifestName, manifest}}
+	if opts.KeyFile != "" {
+		key, err := loadSigningKey(opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		meta = append(meta, metaFile{config.PackageSignatureName, signManifest(key, manifest)})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(opts.Output), ".bundle-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	packageHash := sha256.New()
+	if err := writeBundle(io.MultiWriter(tmp, packageHash), opts.Source, meta, files, dirs); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", opts.Output, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), opts.Output); err != nil {
+		return nil, err
+	}
+
+	// 预先写好旁路文件，本机安装时无需再次计算哈希与文件清单
+	info, err := os.Stat(opts.Output)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fileEntry
+	for _, m := range meta {
+		sum := sha256.Sum256(m.data)
+		entries = append(entries, fileEntry{Path: m.name, Size: int64(len(m.data)), SHA256: hex.EncodeToString(sum[:])})
+	}
+	for _, f := range files {
+		entries = append(entries, fileEntry{Path: f.path, Size: f.size, SHA256: f.hash})
+	}
+	writeSidecar(opts.Output+".sha256", info, hex.EncodeToString(packageHash.Sum(nil))+"  "+filepath.Base(opts.Output)+"\n")
+	writeSidecar(opts.Output+".files", info, formatFileList(entries))
+	return pm, nil
+}
+
+// scanStaging 遍历暂存目录，计算各文件 sha256，并检查路径是否符合资源包约定
+func scanStaging(root string) ([]bundleFile, []string, error) {
+	var files []bundleFile
+	var dirs []string
+	var problems []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			dirs = append(dirs, rel)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			problems = append(problems, fmt.Sprintf("%s: only regular files are supported", rel))
+			return nil
+		}
+		if rel == config.PackageManifestName || rel == config.PackageSignatureName {
+			problems = append(problems, fmt.Sprintf("%s: generated by bundle, remove it from the staging directory", rel))
+			return nil
+		}
+		if problem := checkLayout(rel); problem != "" {
+			problems = append(problems, problem)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, bundleFile{path: rel, mode: info.Mode(), size: info.Size(), hash: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("staging directory does not match the resource package layout:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("staging directory %s has no files", root)
+	}
+	return files, dirs, nil
+}
+
+// checkLayout 组件目录下的文件须符合对应的路径约定；不在任何组件目录下的文件不做限制
+func checkLayout(file string) string {
+	rule, groups := matchLayout(file)
+	if rule == nil {
+		for _, patterns := range resourceComponents {
+			for _, pattern := range patterns {
+				if root, _, _ := strings.Cut(pattern, "{"); strings.HasPrefix(file, root) {
+					return fmt.Sprintf("%s: unexpected path under %s", file, root)
+				}
+			}
+		}
+		return ""
+	}
+	if farch := groups["farch"]; farch != "" && farch != groups["arch"] {
+		return fmt.Sprintf("%s: file is for %s but placed under %s", file, farch, groups["arch"])
+	}
+	if ext := groups["ext"]; ext != "" && (ext == "deb") != (groups["pkg"] == "apt") {
+		return fmt.Sprintf("%s: .%s package placed under %s", file, ext, groups["pkg"])
+	}
+	if dir, version := groups["dir"], groups["version"]; dir != "" && version != "" && dir != strings.ReplaceAll(version, ".", "-") {
+		return fmt.Sprintf("%s: version %s should be placed under %s", file, version, strings.ReplaceAll(version, ".", "-"))
+	}
+	return ""
+}
+
+func matchLayout(file string) (*layoutRule, map[string]string) {
+	for i := range layoutRules {
+		rule := &layoutRules[i]
+		m := rule.re.FindStringSubmatch(file)
+		if m == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for j, name := range rule.re.SubexpNames() {
+			if name != "" && m[j] != "" {
+				groups[name] = m[j]
+			}
+		}
+		return rule, groups
+	}
+	return nil, nil
+}
+
+// buildManifest 根据暂存目录中的文件汇总系统家族、架构、软件版本、chart 与镜像分组
+func buildManifest(name, root string, files []bundleFile) (*config.PackageManifest, error) {
+	imageGroups, err := stagingImageGroups(root)
+	if err != nil {
+		return nil, err
+	}
+	pm := &config.PackageManifest{
+		Name: name,
+		Catalog: config.Catalog{
+			Versions:    map[string][]string{},
+			Charts:      map[string][]string{},
+			ChartFiles:  map[string]map[string]string{},
+			ImageGroups: map[string][]string{},
+		},
+		Files:      map[string]string{},
+		Components: bundleComponents(files),
+	}
+	present := make(map[string]bool, len(files))
+	for _, f := range files {
+		present[f.path] = true
+	}
+	var problems []string
+	for _, f := range files {
+		pm.Files[f.path] = f.hash
+		rule, groups := matchLayout(f.path)
+		if rule == nil {
+			continue
+		}
+		if arch := groups["arch"]; arch != "" {
+			pm.Arches = appendUnique(pm.Arches, arch)
+		}
+		switch groups["pkg"] {
+		case "apt":
+			pm.OSFamilies = appendUnique(pm.OSFamilies, config.OSFamilyUbuntu)
+		case "rpm":
+			pm.OSFamilies = appendUnique(pm.OSFamilies, config.OSFamilyFedora, config.OSFamilyOpenEuler)
+		}
+		if rule.version != "" {
+			version := groups["version"]
+			if version == "" {
+				version = strings.ReplaceAll(groups["dir"], "-", ".")
+			}
+			pm.Versions[rule.version] = appendUnique(pm.Versions[rule.version], version)
+		}
+		if rule.chart == "" {
+			continue
+		}
+		version := groups["chart"]
+		if rule.chart == "multus-cni" {
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.path)))
+			if err != nil {
+				return nil, err
+			}
+			if m := multusImage.FindSubmatch(data); m != nil {
+				version = string(m[1])
+			}
+		}
+		if groups["chart"] != "" {
+			if pm.ChartFiles[rule.chart] == nil {
+				pm.ChartFiles[rule.chart] = map[string]string{}
+			}
+			pm.ChartFiles[rule.chart][version] = path.Base(f.path)
+		}
+		if version != "" {
+			pm.Charts[rule.chart] = appendUnique(pm.Charts[rule.chart], version)
+		} else if _, ok := pm.Charts[rule.chart]; !ok {
+			pm.Charts[rule.chart] = nil
+		}
+		if values := path.Join(path.Dir(f.path), "values.yaml"); rule.values && groups["chart"] != "" && !present[values] {
+			problems = append(problems, fmt.Sprintf("%s: missing %s", f.path, values))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("staging directory does not match the resource package layout:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	// 镜像分组来自暂存目录或内置的 images.yaml，只记录资源包中实际包含的组件
+	if len(pm.Versions["k8s"]) > 0 {
+		pm.ImageGroups["k8s-images"] = imageGroups["k8s-images"]
+	}
+	for chart := range pm.Charts {
+		if images, ok := imageGroups[chart+"-images"]; ok {
+			pm.ImageGroups[chart+"-images"] = images
+		}
+	}
+	for _, values := range pm.Versions {
+		sort.Strings(values)
+	}
+	for _, values := range pm.Charts {
+		sort.Strings(values)
+	}
+	sort.Strings(pm.Arches)
+	sort.Strings(pm.OSFamilies)
+	return pm, nil
+}
+
+// stagingImageGroups 暂存目录根下有 images.yaml 时以其为准，否则使用内置的 images.yaml
+func stagingImageGroups(root string) (map[string][]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, bundleImagesName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return config.ImagesByGroup()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config.ParseImageGroups(data)
+}
+
+func appendUnique(values []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(values, item) {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// writeBundle 写出 tar.gz：manifest.yaml 与签名在最前，便于读取时尽早找到，其余条目按路径排序
+func writeBundle(w io.Writer, root string, meta []metaFile, files []bundleFile, dirs []string) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	header := func(name string, mode int64, size int64, typeflag byte) *tar.Header {
+		return &tar.Header{Name: name, Mode: mode, Size: size, Typeflag: typeflag, ModTime: time.Unix(0, 0), Format: tar.FormatPAX}
+	}
+	for _, m := range meta {
+		if err := tw.WriteHeader(header(m.name, 0644, int64(len(m.data)), tar.TypeReg)); err != nil {
+			return err
+		}
+		if _, err := tw.Write(m.data); err != nil {
+			return err
+		}
+	}
+
+	type entry struct {
+		name string
+		file *bundleFile
+	}
+	entries := make([]entry, 0, len(dirs)+len(files))
+	for _, d := range dirs {
+		entries = append(entries, entry{name: d + "/"})
+	}
+	for i := range files {
+		entries = append(entries, entry{name: files[i].path, file: &files[i]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	for _, e := range entries {
+		if e.file == nil {
+			if err := tw.WriteHeader(header(e.name, 0755, 0, tar.TypeDir)); err != nil {
+				return err
+			}
+			continue
+		}
+		var mode int64 = 0644
+		if e.file.mode&0111 != 0 {
+			mode = 0755
+		}
+		if err := tw.WriteHeader(header(e.name, mode, e.file.size, tar.TypeReg)); err != nil {
+			return err
+		}
+		if err := copyFile(tw, filepath.Join(root, filepath.FromSlash(e.file.path)), e.file.hash); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyFile 写入的内容须与扫描时计算的 sha256 一致，打包期间文件被修改时报错
+func copyFile(w io.Writer, file string, hash string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != hash {
+		return fmt.Errorf("%s changed while bundling", file)
+	}
+	return nil
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
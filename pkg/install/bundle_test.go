@@ -0,0 +1,139 @@
+package install
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeStaging(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBundle(t *testing.T) {
+	staging := writeStaging(t, map[string]string{
+		"VERSION": "1.0\n",
+		"docker-ce/docker/amd64/29-2-0/docker-29.2.0.tgz":                          "docker",
+		"docker-ce/containerd/amd64/2-2-1/containerd-2.2.1-linux-amd64.tar.gz":     "containerd",
+		"docker-ce/runc/amd64/1-3-4/runc.amd64":                                    "runc",
+		"nerdctl/amd64/2-2-1/nerdctl-2.2.1-linux-amd64.tar.gz":                     "nerdctl",
+		"k8s/amd64/apt/1-34-4/kubeadm.deb":                                         "deb",
+		"k8s/amd64/rpm/1-34-4/kubeadm.rpm":                                         "rpm",
+		"helm/amd64/helm-v3.19.0-linux-amd64.tar.gz":                               "helm",
+		"helm-resource/cni/kube-ovn/kube-ovn-v1.15.2.tgz":                          "chart",
+		"helm-resource/cni/kube-ovn/values.yaml":                                   "image: kube-ovn\n",
+		"cni/multus-cni/multus-daemonset-thick.yml":                                "image: ghcr.io/k8snetworkplumbingwg/multus-cni:snapshot-thick\n",
+		"common-tools/amd64/apt/conntrack.deb":                                     "conntrack",
+		"docker-runtime/ascend/amd64/Ascend-docker-runtime_6.0.0_linux-x86_64.run": "ascend",
+	})
+	out := filepath.Join(t.TempDir(), "resources-ubuntu-amd64.tar.gz")
+	pm, err := Bundle(BundleOptions{Source: staging, Output: out, Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := os.ReadFile(out)
+
+	if !slices.Equal(pm.Arches, []string{"amd64"}) || !slices.Equal(pm.OSFamilies, []string{"fedora", "openeuler", "ubuntu"}) {
+		t.Errorf("arches = %v, os families = %v", pm.Arches, pm.OSFamilies)
+	}
+	for key, want := range map[string]string{"dockerce": "29.2.0", "containerd": "2.2.1", "runc": "1.3.4", "nerdctl": "2.2.1", "k8s": "1.34.4"} {
+		if !slices.Equal(pm.Versions[key], []string{want}) {
+			t.Errorf("versions.%s = %v, want %s", key, pm.Versions[key], want)
+		}
+	}
+	if !slices.Equal(pm.Charts["kube-ovn"], []string{"1.15.2"}) || !slices.Equal(pm.Charts["multus-cni"], []string{"snapshot-thick"}) {
+		t.Errorf("charts = %v", pm.Charts)
+	}
+	if file := pm.ChartFiles["kube-ovn"]["1.15.2"]; file != "kube-ovn-v1.15.2.tgz" {
+		t.Errorf("chart_files.kube-ovn = %v", pm.ChartFiles["kube-ovn"])
+	}
+	for _, group := range []string{"k8s-images", "kube-ovn-images", "multus-cni-images"} {
+		if len(pm.ImageGroups[group]) == 0 {
+			t.Errorf("image group %s missing", group)
+		}
+	}
+	if !slices.Equal(pm.Components[componentDocker], resourceComponents[componentDocker]) || pm.Components[componentHAProxy] != nil {
+		t.Errorf("components = %v, want only components present in the package", pm.Components)
+	}
+	if len(pm.Files) != 13 {
+		t.Errorf("manifest lists %d files, want 13", len(pm.Files))
+	}
+
+	// 读取资源包得到相同的 manifest，预写的文件清单与实际内容一致
+	loaded, err := newFileCache(readPackageManifest).start(out).wait(t.Context())
+	if err != nil || loaded == nil || loaded.Name != "test" || len(loaded.Files) != 13 || len(loaded.Components) != len(pm.Components) {
+		t.Fatalf("loaded manifest = %+v, %v", loaded, err)
+	}
+	cached, err := newFileCache(fileList).start(out).wait(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(out + ".files")
+	actual, err := newFileCache(fileList).start(out).wait(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 14 || actual[0].Path != "manifest.yaml" {
+		t.Errorf("package entries = %v", actual)
+	}
+	for _, f := range actual {
+		if !slices.Contains(cached, f) {
+			t.Errorf("precomputed file list missing %+v", f)
+		}
+	}
+
+	// 暂存目录修改时间变化不影响结果，相同输入得到字节相同的资源包
+	os.Chtimes(filepath.Join(staging, "VERSION"), time.Now(), time.Now().Add(time.Hour))
+	if _, err := Bundle(BundleOptions{Source: staging, Output: out, Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := os.ReadFile(out); !bytes.Equal(first, second) {
+		t.Error("bundle is not deterministic")
+	}
+}
+
+func TestBundleLayout(t *testing.T) {
+	staging := writeStaging(t, map[string]string{
+		"VERSION": "1.0\n",
+		"docker-ce/containerd/amd64/2-2-1/containerd-2.2.1-linux-arm64.tar.gz": "containerd",
+		"k8s/amd64/apt/1-34-4/kubeadm.rpm":                                     "rpm",
+		"nerdctl/amd64/2-2-0/nerdctl-2.2.1-linux-amd64.tar.gz":                 "nerdctl",
+		"helm/x86_64/helm-v3.19.0-linux-amd64.tar.gz":                          "helm",
+		"manifest.yaml": "name: stale\n",
+	})
+	_, err := Bundle(BundleOptions{Source: staging, Output: filepath.Join(t.TempDir(), "out.tar.gz")})
+	if err == nil {
+		t.Fatal("bundle with invalid layout should fail")
+	}
+	for _, want := range []string{
+		"containerd-2.2.1-linux-arm64.tar.gz: file is for arm64 but placed under amd64",
+		"kubeadm.rpm: .rpm package placed under apt",
+		"nerdctl-2.2.1-linux-amd64.tar.gz: version 2.2.1 should be placed under 2-2-1",
+		"helm/x86_64/helm-v3.19.0-linux-amd64.tar.gz: unexpected path under helm/",
+		"manifest.yaml: generated by bundle",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+
+	staging = writeStaging(t, map[string]string{"helm-resource/hami/hami/hami-2.7.1.tgz": "chart"})
+	if _, err := Bundle(BundleOptions{Source: staging, Output: filepath.Join(t.TempDir(), "out.tar.gz")}); err == nil || !strings.Contains(err.Error(), "missing helm-resource/hami/hami/values.yaml") {
+		t.Errorf("chart without values.yaml: %v", err)
+	}
+}
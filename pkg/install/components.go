@@ -0,0 +1,132 @@
+package install
+
+import (
+	"slices"
+	"strings"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/install/strategy"
+	"k8s-offline-tool/pkg/runner"
+)
+
+// 资源包组件，由各安装步骤在 runner.Step.Resources 中声明
+const (
+	componentCommonTools   = "common-tools"
+	componentHAProxy       = "haproxy"
+	componentKeepalived    = "keepalived"
+	componentDocker        = "docker"
+	componentContainerd    = "containerd"
+	componentRunc          = "runc"
+	componentNerdctl       = "nerdctl"
+	componentAscendRuntime = "ascend-runtime"
+	componentKubernetes    = "kubernetes"
+	componentHelm          = "helm"
+	componentKubeOvn       = "kube-ovn"
+	componentMultus        = "multus"
+	componentPrometheus    = "kube-prometheus-stack"
+	componentHami          = "hami"
+	componentHamiWebUI     = "hami-webui"
+	componentAscendVNPU    = "ascend-vnpu-device-plugin"
+)
+
+// resourceComponents 各组件在资源包中的目录，{arch} 替换为节点架构，{pkg} 替换为 apt 或 rpm。
+// 第一个占位符之前的部分为组件根目录，根目录下不属于本节点的架构、包格式的文件不会分发；
+// 不在任何组件根目录下的文件所有节点都会收到。
+// bundle 将资源包包含的组件写入 manifest.yaml 的 components，分发时以其为准，
+// 此处的内置映射仅用于制作资源包以及没有 components 的旧资源包
+var resourceComponents = map[string][]string{
+	componentCommonTools:   {"common-tools/{arch}/{pkg}/"},
+	componentHAProxy:       {"ha/haproxy/{arch}/{pkg}/"},
+	componentKeepalived:    {"ha/keepalived/{arch}/{pkg}/"},
+	componentDocker:        {"docker-ce/docker/{arch}/"},
+	componentContainerd:    {"docker-ce/containerd/{arch}/"},
+	componentRunc:          {"docker-ce/runc/{arch}/"},
+	componentNerdctl:       {"nerdctl/{arch}/"},
+	componentAscendRuntime: {"docker-runtime/ascend/{arch}/"},
+	componentKubernetes:    {"k8s/{arch}/{pkg}/"},
+	componentHelm:          {"helm/{arch}/"},
+	componentKubeOvn:       {"helm-resource/cni/kube-ovn/"},
+	componentMultus:        {"cni/multus-cni/"},
+	componentPrometheus:    {"helm-resource/kube-prometheus-stack/"},
+	componentHami:          {"helm-resource/hami/hami/"},
+	componentHamiWebUI:     {"helm-resource/hami/hami-webui/"},
+	componentAscendVNPU:    {"helm-resource/hami/ascend-vnpu-device-plugin/"},
+}
+
+// requiredComponents 汇总步骤声明的组件，按名称排序去重
+func requiredComponents(steps []runner.Step) []string {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Resources...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// packageFormat 节点使用的系统包格式
+func packageFormat(installer strategy.NodeInstaller) string {
+	if _, ok := installer.(*strategy.UbuntuInstaller); ok {
+		return "apt"
+	}
+	return "rpm"
+}
+
+// componentSelector 判断资源包中的文件是否需要分发给节点
+type componentSelector struct {
+	roots   []string // 所有组件的根目录
+	include []string // 节点所需组件替换占位符后的目录
+}
+
+// packageComponents 资源包声明的组件目录，未声明时使用内置映射
+func packageComponents(pm *config.PackageManifest) map[string][]string {
+	if pm == nil || len(pm.Components) == 0 {
+		return resourceComponents
+	}
+	return pm.Components
+}
+
+// bundleComponents 资源包中实际包含文件的组件及其目录，写入 manifest.yaml
+func bundleComponents(files []bundleFile) map[string][]string {
+	components := map[string][]string{}
+	for name, patterns := range resourceComponents {
+		for _, pattern := range patterns {
+			root, _, _ := strings.Cut(pattern, "{")
+			if slices.ContainsFunc(files, func(f bundleFile) bool { return strings.HasPrefix(f.path, root) }) {
+				components[name] = patterns
+				break
+			}
+		}
+	}
+	return components
+}
+
+// newComponentSelector declared 为组件 -> 目录，components 为节点所需组件
+func newComponentSelector(declared map[string][]string, components []string, arch, pkg string) *componentSelector {
+	s := &componentSelector{}
+	replacer := strings.NewReplacer("{arch}", arch, "{pkg}", pkg)
+	for name, patterns := range declared {
+		needed := slices.Contains(components, name)
+		for _, pattern := range patterns {
+			root, _, _ := strings.Cut(pattern, "{")
+			s.roots = append(s.roots, root)
+			if needed {
+				s.include = append(s.include, replacer.Replace(pattern))
+			}
+		}
+	}
+	return s
+}
+
+func (s *componentSelector) match(file string) bool {
+	for _, prefix := range s.include {
+		if strings.HasPrefix(file, prefix) {
+			return true
+		}
+	}
+	for _, root := range s.roots {
+		if strings.HasPrefix(file, root) {
+			return false
+		}
+	}
+	return true
+}
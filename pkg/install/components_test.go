@@ -0,0 +1,41 @@
+package install
+
+import (
+	"testing"
+
+	"k8s-offline-tool/pkg/config"
+)
+
+func TestComponentSelector(t *testing.T) {
+	// 资源包声明的目录与内置映射不同时以资源包为准
+	declared := &config.PackageManifest{Components: map[string][]string{
+		componentDocker:     {"runtime/docker/{arch}/"},
+		componentKubernetes: {"kube/{pkg}/{arch}/"},
+	}}
+	tests := []struct {
+		name       string
+		pm         *config.PackageManifest
+		components []string
+		file       string
+		want       bool
+	}{
+		{"declared component", declared, []string{componentDocker}, "runtime/docker/amd64/docker.tgz", true},
+		{"declared component other arch", declared, []string{componentDocker}, "runtime/docker/arm64/docker.tgz", false},
+		{"declared component not needed", declared, []string{componentKubernetes}, "runtime/docker/amd64/docker.tgz", false},
+		{"declared pkg placeholder", declared, []string{componentKubernetes}, "kube/apt/amd64/kubeadm.deb", true},
+		{"declared pkg placeholder other format", declared, []string{componentKubernetes}, "kube/rpm/amd64/kubeadm.rpm", false},
+		{"builtin path not declared", declared, nil, "docker-ce/docker/arm64/docker.tgz", true},
+		{"fallback for old package", &config.PackageManifest{}, []string{componentDocker}, "docker-ce/docker/arm64/docker.tgz", false},
+		{"fallback without manifest", nil, []string{componentDocker}, "docker-ce/docker/amd64/docker.tgz", true},
+		{"file outside components", declared, nil, "VERSION", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newComponentSelector(packageComponents(tt.pm), tt.components, "amd64", "apt")
+			if got := s.match(tt.file); got != tt.want {
+				t.Errorf("match(%s) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
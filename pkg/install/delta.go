@@ -0,0 +1,360 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/ui"
+)
+
+const (
+	// remoteFileListName 节点解压目录中记录当前文件清单的文件，下次增量同步据此删除过期文件
+	remoteFileListName = ".files"
+	deltaPackageName   = "resources.delta.tar.gz"
+)
+
+// packageFiles 进程内共享的资源包文件清单
+var packageFiles = newFileCache(sourceFileList)
+
+// fileEntry 资源包中的一个普通文件，Path 为相对解压目录的路径
+type fileEntry struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// fileList 优先读取旁路的 .files 文件，否则解压遍历资源包逐个计算文件哈希
+func fileList(file string, info os.FileInfo) ([]fileEntry, error) {
+	sidecar := file + ".files"
+	if lines, ok := readSidecar(sidecar, info); ok {
+		if files, err := parseFileList(strings.Join(lines, "\n")); err == nil {
+			return files, nil
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	var files []fileEntry
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %v", file, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s in %s: %v", hdr.Name, file, err)
+		}
+		files = append(files, fileEntry{Path: entryPath(hdr.Name), Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+	}
+	writeSidecar(sidecar, info, formatFileList(files))
+	return files, nil
+}
+
+// entryPath 统一 tar 条目名，去掉 "./" 前缀
+func entryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("./"+name), "./")
+}
+
+// formatFileList 每行 "<sha256> <字节数> <路径>"
+func formatFileList(files []fileEntry) string {
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s %d %s\n", f.SHA256, f.Size, f.Path)
+	}
+	return b.String()
+}
+
+func parseFileList(data string) ([]fileEntry, error) {
+	var files []fileEntry
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid file list line %q", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file list line %q", line)
+		}
+		files = append(files, fileEntry{Path: fields[2], Size: size, SHA256: fields[0]})
+	}
+	return files, nil
+}
+
+// writeDelta 从资源包中挑出 changed 中的普通文件写成新的 tar.gz；目录、链接等条目体积很小，全部保留。
+// changed 为文件路径到文件清单中 sha256 的映射，资源包在生成清单或校验签名后被改动时报错
+func writeDelta(pkg string, changed map[string]string, dst io.Writer) error {
+	if !isArchive(pkg) {
+		return writeDirDelta(pkg, changed, dst)
+	}
+	f, err := os.Open(pkg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	zw := gzip.NewWriter(dst)
+	tw := tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %v", pkg, err)
+		}
+		want, ok := changed[entryPath(hdr.Name)]
+		if hdr.Typeflag == tar.TypeReg && !ok {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := copyVerified(tw, tr, hdr.Name, want); err != nil {
+				return fmt.Errorf("%v in %s", err, pkg)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyVerified 复制文件内容并核对 sha256 与文件清单一致
+func copyVerified(dst io.Writer, src io.Reader, name, want string) error {
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, h), src); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%s has sha256 %s, expected %s: resources changed after they were listed", name, got, want)
+	}
+	return nil
+}
+
+// nulList 将路径拼成 NUL 分隔的列表，经标准输入交给远端 xargs -0，避免引号转义问题
+func nulList(paths []string) io.Reader {
+	var b bytes.Buffer
+	for _, p := range paths {
+		b.WriteString(p)
+		b.WriteByte(0)
+	}
+	return &b
+}
+
+// packageFileList 等待本地资源包文件清单计算完成
+func (m *Manager) packageFileList() ([]fileEntry, error) {
+	files, err := packageFiles.start(m.resourcePackage).wait(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build resource package file list: %w", err)
+	}
+	return files, nil
+}
+
+// requiredFiles 返回需要分发给本节点的文件，以及是否为资源包的全部文件。
+// p2p 模式下节点需要完整资源包作为中继来源，不做筛选。
+func (m *Manager) requiredFiles() ([]fileEntry, bool, error) {
+	files, err := m.packageFileList()
+	if err != nil {
+		return nil, false, err
+	}
+	if m.distributor != nil {
+		return files, true, nil
+	}
+	pm, err := m.packageManifest(m.resourcePackage)
+	if err != nil {
+		return nil, false, err
+	}
+	selector := newComponentSelector(packageComponents(pm), m.components, m.context.Arch, packageFormat(m.installer))
+	var required []fileEntry
+	for _, f := range files {
+		if selector.match(f.Path) {
+			required = append(required, f)
+		}
+	}
+	return required, len(required) == len(files), nil
+}
+
+// distributionMarker 分发完成标记的内容：资源包哈希，直传模式下附带本节点的组件列表，
+// 节点角色或加速卡变化后组件不同，会重新同步
+func (m *Manager) distributionMarker() (string, error) {
+	localHash, err := m.localHash()
+	if err != nil || m.distributor != nil {
+		return localHash, err
+	}
+	return fmt.Sprintf("%s components=%s", localHash, strings.Join(m.components, ",")), nil
+}
+
+// remoteHashes 在节点上计算清单中各文件的 sha256，不存在的文件不出现在结果中
+func (m *Manager) remoteHashes(files []fileEntry) (map[string]string, error) {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	cmd := fmt.Sprintf("cd %s && xargs -0 -r sha256sum -- 2>/dev/null; true", m.context.RemoteTmpDir)
+	res, err := m.exec.RunStdin(m.cmdContext(), cmd, nulList(paths))
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(files))
+	for _, line := range strings.Split(res.Stdout, "\n") {
+		if hash, name, ok := strings.Cut(line, "  "); ok {
+			hashes[name] = hash
+		}
+	}
+	return hashes, nil
+}
+
+// syncDelta 只传输本节点所需且与节点上哈希不一致的文件，并删除上次分发后已不再需要的文件。
+// 返回 false 表示节点上没有可比对的文件且需要全部文件，应完整分发资源包；
+// 资源目录与镜像没有完整的资源包，总是逐文件同步。
+func (m *Manager) syncDelta(nodeCtx *ui.NodeContext) (bool, error) {
+	dir := m.context.RemoteTmpDir
+	res, err := m.probe(fmt.Sprintf("cat %s", path.Join(dir, remoteFileListName)))
+	if err != nil {
+		return false, err
+	}
+	var previous []fileEntry
+	hasPrevious := res.ExitCode == 0
+	if hasPrevious {
+		if previous, err = parseFileList(res.Stdout); err != nil {
+			hasPrevious = false
+		}
+	}
+
+	nodeCtx.UpdateResourceProgress("正在比对节点上的资源文件...")
+	files, all, err := m.requiredFiles()
+	if err != nil {
+		return false, err
+	}
+	if !hasPrevious && all && isArchive(m.resourcePackage) {
+		return false, nil
+	}
+	remote := map[string]string{}
+	if hasPrevious {
+		if remote, err = m.remoteHashes(files); err != nil {
+			return false, fmt.Errorf("failed to checksum remote resources: %w", err)
+		}
+	}
+
+	changed := make(map[string]string)
+	var changedBytes int64
+	current := make(map[string]bool, len(files))
+	for _, f := range files {
+		current[f.Path] = true
+		if remote[f.Path] != f.SHA256 {
+			changed[f.Path] = f.SHA256
+			changedBytes += f.Size
+		}
+	}
+	// 旧的完整资源包已与解压内容不一致，一并删除
+	removed := []string{"resources.tar.gz"}
+	for _, f := range previous {
+		if !current[f.Path] {
+			removed = append(removed, f.Path)
+		}
+	}
+
+	if len(changed) > 0 {
+		if config.IsMirrorURL(m.resourcePackage) {
+			err = m.pullFromMirror(nodeCtx, files, changed)
+		} else {
+			err = m.transferDelta(nodeCtx, changed)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	rmCmd := fmt.Sprintf("cd %s && xargs -0 -r rm -f --", dir)
+	if _, err := m.exec.RunStdin(m.cmdContext(), rmCmd, nulList(removed)); err != nil {
+		return false, fmt.Errorf("failed to remove stale resources: %w", err)
+	}
+	if config.IsMirrorURL(m.resourcePackage) {
+		// 镜像文件清单不含大小
+		fmt.Fprintf(m.output, "[%s]     从镜像同步 %d 个文件，删除 %d 个过期文件\n", m.nodeCfg.IP, len(changed), len(removed)-1)
+	} else {
+		fmt.Fprintf(m.output, "[%s]     按需同步 %d 个文件（%.1f MB），删除 %d 个过期文件\n",
+			m.nodeCfg.IP, len(changed), float64(changedBytes)/1024/1024, len(removed)-1)
+	}
+	return true, nil
+}
+
+// transferDelta 在本机生成只含变化文件的 tar.gz，按 upload_mode 传输并解压到节点
+func (m *Manager) transferDelta(nodeCtx *ui.NodeContext, changed map[string]string) error {
+	tmp, err := os.CreateTemp("", "resources-delta-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := sha256.New()
+	if err := writeDelta(m.resourcePackage, changed, io.MultiWriter(tmp, h)); err != nil {
+		return fmt.Errorf("failed to build delta package: %v", err)
+	}
+	deltaHash := hex.EncodeToString(h.Sum(nil))
+	hashFn := func() (string, error) { return deltaHash, nil }
+
+	if m.globalCfg.Distribution.UploadMode == config.UploadModeStream {
+		return m.streamPackage(nodeCtx, tmp.Name(), hashFn)
+	}
+	remoteDelta := path.Join(m.context.RemoteTmpDir, deltaPackageName)
+	if err := m.uploadVerified(nodeCtx, tmp.Name(), remoteDelta, hashFn); err != nil {
+		return err
+	}
+	nodeCtx.UpdateResourceProgress("正在远端解压增量资源...")
+	extractCmd := fmt.Sprintf("cd %s && tar -xzf %s", m.context.RemoteTmpDir, deltaPackageName)
+	if _, err := m.runCommand(extractCmd); err != nil {
+		return fmt.Errorf("extract delta package failed: %w", err)
+	}
+	if _, err := m.runCommand(fmt.Sprintf("rm -f %s", remoteDelta)); err != nil {
+		return fmt.Errorf("failed to remove delta package: %w", err)
+	}
+	return nil
+}
+
+// writeRemoteFileList 分发完成后在节点上记录当前文件清单
+func (m *Manager) writeRemoteFileList() error {
+	files, _, err := m.requiredFiles()
+	if err != nil {
+		return err
+	}
+	content := formatFileList(files)
+	remotePath := path.Join(m.context.RemoteTmpDir, remoteFileListName)
+	if err := m.exec.PutFile(m.ctx, remotePath, strings.NewReader(content), int64(len(content)), nil); err != nil {
+		return fmt.Errorf("failed to write resource file list: %w", err)
+	}
+	return nil
+}
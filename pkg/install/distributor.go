@@ -0,0 +1,166 @@
+package install
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"k8s-offline-tool/pkg/config"
+)
+
+// peerSource 已持有完整资源包并运行临时 HTTP 中继的节点
+type peerSource struct {
+	IP     string
+	URL    string
+	active int  // 正在从该节点拉取的下载数
+	failed bool // 有节点从它拉取失败，不再分配
+	stop   func()
+}
+
+// Distributor 在所有节点的 Manager 之间协调 p2p 分发：
+// 前 Seeds 个节点由本机直接上传，之后的节点从已就绪节点拉取，每个节点最多同时服务 Fanout 个下载，
+// 已完成拉取的节点再作为新的来源，形成分发树。
+type Distributor struct {
+	seeds  int
+	fanout int
+	port   int
+	token  string // 中继 URL 中的随机路径，避免被同网段其他主机猜到
+
+	mu      sync.Mutex
+	changed chan struct{}          // 来源或进行中传输发生变化时关闭并替换
+	pools   map[string]*sourcePool // 按资源包路径区分，节点只从持有相同资源包的节点拉取
+}
+
+// sourcePool 同一资源包的分发状态
+type sourcePool struct {
+	direct  int // 已分配的直传次数
+	pending int // 进行中的传输（直传 + 拉取），可能产生新的来源
+	sources []*peerSource
+}
+
+// NewDistributor 未启用 p2p 分发时返回 nil，Manager 退回逐节点直传
+func NewDistributor(cfg *config.Config) *Distributor {
+	if cfg.Distribution.Mode != config.DistributionModeP2P {
+		return nil
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return &Distributor{
+		seeds:   cfg.Distribution.Seeds,
+		fanout:  cfg.Distribution.Fanout,
+		port:    cfg.Distribution.RelayPort,
+		token:   hex.EncodeToString(buf),
+		changed: make(chan struct{}),
+		pools:   make(map[string]*sourcePool),
+	}
+}
+
+// pool 返回资源包对应的分发状态，需持有锁
+func (d *Distributor) pool(pkg string) *sourcePool {
+	p, ok := d.pools[pkg]
+	if !ok {
+		p = &sourcePool{}
+		d.pools[pkg] = p
+	}
+	return p
+}
+
+// acquire 为一个节点分配资源包 pkg 的来源，返回 nil 表示由本机直接上传。
+// 没有空闲来源但仍有传输进行中时等待，避免所有节点都回退到本机上传。
+func (d *Distributor) acquire(ctx context.Context, pkg string) (*peerSource, error) {
+	for {
+		d.mu.Lock()
+		p := d.pool(pkg)
+		if src := d.pick(p); src != nil {
+			src.active++
+			p.pending++
+			d.mu.Unlock()
+			return src, nil
+		}
+		if p.direct < d.seeds || p.pending == 0 {
+			// 种子节点，或者已没有可能就绪的来源
+			p.direct++
+			p.pending++
+			d.mu.Unlock()
+			return nil, nil
+		}
+		changed := d.changed
+		d.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// pick 选择负载最低且未满的来源，需持有锁
+func (d *Distributor) pick(p *sourcePool) *peerSource {
+	var best *peerSource
+	for _, src := range p.sources {
+		if src.failed || src.active >= d.fanout {
+			continue
+		}
+		if best == nil || src.active < best.active {
+			best = src
+		}
+	}
+	return best
+}
+
+// release 结束 acquire 分配的传输；src 为 nil 表示直传，ok 为 false 时该来源不再使用
+func (d *Distributor) release(pkg string, src *peerSource, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pool(pkg).pending--
+	if src != nil {
+		src.active--
+		if !ok {
+			src.failed = true
+		}
+	}
+	d.notify()
+}
+
+// addSource 登记新就绪的节点，应在 release 之前调用，等待中的节点才能直接拉取
+func (d *Distributor) addSource(pkg string, src *peerSource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.pool(pkg)
+	p.sources = append(p.sources, src)
+	d.notify()
+}
+
+func (d *Distributor) notify() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// Close 停止所有节点上的临时中继
+func (d *Distributor) Close() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	var sources []*peerSource
+	for _, p := range d.pools {
+		sources = append(sources, p.sources...)
+		p.sources = nil
+	}
+	d.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		if src.stop == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(stop func()) {
+			defer wg.Done()
+			stop()
+		}(src.stop)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package install
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s-offline-tool/pkg/config"
+)
+
+const pkg = "resources.tar.gz"
+
+func TestDistributorFanout(t *testing.T) {
+	cfg := &config.Config{Distribution: config.DistributionConfig{Mode: config.DistributionModeP2P, Seeds: 1, Fanout: 2}}
+	d := NewDistributor(cfg)
+	ctx := context.Background()
+
+	// 第一个节点作为种子直传
+	seed, err := d.acquire(ctx, pkg)
+	if err != nil || seed != nil {
+		t.Fatalf("first acquire = %v, %v, want direct upload", seed, err)
+	}
+
+	// 种子未就绪前，后续节点等待而不是回退直传
+	got := make(chan *peerSource, 1)
+	go func() {
+		src, _ := d.acquire(ctx, pkg)
+		got <- src
+	}()
+	select {
+	case src := <-got:
+		t.Fatalf("acquire returned %v before seed was ready", src)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.addSource(pkg, &peerSource{IP: "10.0.0.1"})
+	d.release(pkg, nil, true)
+	first := <-got
+	if first == nil || first.IP != "10.0.0.1" {
+		t.Fatalf("acquire = %v, want seed 10.0.0.1", first)
+	}
+
+	// fanout 为 2，种子仍可服务一个下载
+	second, _ := d.acquire(ctx, pkg)
+	if second == nil || second.IP != "10.0.0.1" {
+		t.Fatalf("acquire = %v, want seed 10.0.0.1", second)
+	}
+
+	// 拉取失败后该来源被摒弃；没有进行中的传输时回退直传
+	d.release(pkg, second, false)
+	d.release(pkg, first, true)
+	fallback, _ := d.acquire(ctx, pkg)
+	if fallback != nil {
+		t.Fatalf("acquire = %v, want direct upload fallback", fallback)
+	}
+}
+
+func TestDistributorCancel(t *testing.T) {
+	cfg := &config.Config{Distribution: config.DistributionConfig{Mode: config.DistributionModeP2P, Seeds: 1, Fanout: 1}}
+	d := NewDistributor(cfg)
+	if _, err := d.acquire(context.Background(), pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := d.acquire(ctx, pkg); err != context.Canceled {
+		t.Fatalf("acquire err = %v, want context.Canceled", err)
+	}
+}
+
+func TestDistributorPerPackage(t *testing.T) {
+	cfg := &config.Config{Distribution: config.DistributionConfig{Mode: config.DistributionModeP2P, Seeds: 1, Fanout: 3}}
+	d := NewDistributor(cfg)
+	ctx := context.Background()
+
+	if src, _ := d.acquire(ctx, "amd64.tar.gz"); src != nil {
+		t.Fatalf("acquire = %v, want direct upload", src)
+	}
+	d.addSource("amd64.tar.gz", &peerSource{IP: "10.0.0.1"})
+	d.release("amd64.tar.gz", nil, true)
+
+	// 其他资源包的节点不能从持有 amd64 资源包的节点拉取，需要自己的种子
+	if src, _ := d.acquire(ctx, "arm64.tar.gz"); src != nil {
+		t.Fatalf("arm64 acquire = %v, want direct upload", src)
+	}
+	if src, _ := d.acquire(ctx, "amd64.tar.gz"); src == nil || src.IP != "10.0.0.1" {
+		t.Fatalf("amd64 acquire = %v, want 10.0.0.1", src)
+	}
+}
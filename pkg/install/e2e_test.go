@@ -0,0 +1,800 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/executor"
+	"k8s-offline-tool/pkg/sshtest"
+	"k8s-offline-tool/pkg/ui"
+
+	"gopkg.in/yaml.v3"
+)
+
+// e2eNode 一个模拟节点及其在配置中的角色
+type e2eNode struct {
+	node    *sshtest.Node
+	master  bool
+	primary bool
+}
+
+type e2eCluster struct {
+	cfg     *config.Config
+	servers []*sshtest.Server
+}
+
+// newE2ECluster 为每个节点启动模拟 SSH 服务（127.0.0.2 起依次分配 IP，系统不支持回环别名时跳过测试），生成对应的配置
+func newE2ECluster(t *testing.T, mode string, nodes ...e2eNode) *e2eCluster {
+	t.Helper()
+	cfg := &config.Config{
+		ResourcePackage: singlePackage(writeResourcePackage(t)),
+		User:            "root",
+		Password:        "root",
+		HostKeyPolicy:   config.HostKeyPolicyInsecure,
+		InstallMode:     mode,
+	}
+	c := &e2eCluster{cfg: cfg}
+	for i, n := range nodes {
+		srv := sshtest.NewServer(t, fmt.Sprintf("127.0.0.%d", i+2), n.node)
+		c.servers = append(c.servers, srv)
+		cfg.Nodes = append(cfg.Nodes, config.NodeConfig{
+			IP:              srv.Host,
+			SSHPort:         srv.Port,
+			IsMaster:        n.master,
+			IsPrimaryMaster: n.primary,
+			Interface:       "eth0",
+		})
+	}
+	return c
+}
+
+// run 与 main 相同的执行顺序：Master 顺序执行（主节点优先），Worker 并发执行
+func (c *e2eCluster) run(t *testing.T) []error {
+	t.Helper()
+	if err := config.ApplyDefaultsAndValidate(c.cfg); err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := LoadCatalog(c.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ApplyVersions(c.cfg, catalog, nil); err != nil {
+		t.Fatal(err)
+	}
+	errs := make([]error, len(c.cfg.Nodes))
+	var masters, workers []int
+	for i, node := range c.cfg.Nodes {
+		switch {
+		case node.IsPrimaryMaster:
+			masters = append([]int{i}, masters...)
+		case node.IsMaster:
+			masters = append(masters, i)
+		default:
+			workers = append(workers, i)
+		}
+	}
+	for _, i := range masters {
+		if errs[i] = c.runNode(i); errs[i] != nil {
+			return errs
+		}
+	}
+	var wg sync.WaitGroup
+	for _, i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.runNode(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (c *e2eCluster) runNode(i int) error {
+	ctx := context.Background()
+	node := &c.cfg.Nodes[i]
+	nodeCtx := ui.NewNodeContext(node.IP, "node", 0, c.cfg.DryRun)
+	mgr, err := NewManager(ctx, c.cfg, node, i+1, len(c.cfg.Nodes), nodeCtx)
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+	return mgr.Run(ctx, nodeCtx, c.cfg.DryRun)
+}
+
+func writeResourcePackage(t *testing.T) string {
+	return writePackage(t, map[string]string{
+		"helm-resource/cni/kube-ovn/values.yaml":    "image: docker.io/kubeovn/kube-ovn\n",
+		"cni/multus-cni/multus-daemonset-thick.yml": "kind: DaemonSet\n",
+	})
+}
+
+func singlePackage(path string) config.ResourcePackages {
+	return config.ResourcePackages{{Path: path}}
+}
+
+// writePackage 生成包含 files 的资源包
+func writePackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "resources.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func countCommands(n *sshtest.Node, prefix string) int {
+	count := 0
+	for _, cmd := range n.Commands() {
+		if strings.HasPrefix(cmd, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestE2EFullMode(t *testing.T) {
+	master, ubuntuWorker, fedoraWorker := sshtest.OpenEuler(), sshtest.Ubuntu(), sshtest.Fedora()
+	c := newE2ECluster(t, config.InstallModeFull,
+		e2eNode{node: master, master: true},
+		e2eNode{node: ubuntuWorker},
+		e2eNode{node: fedoraWorker},
+	)
+	c.cfg.Addons.KubeOvn.Enabled = true
+	c.cfg.Addons.MultusCNI.Enabled = true
+
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+
+	if countCommands(master, "kubeadm init") != 1 {
+		t.Errorf("master kubeadm init count = %d, want 1", countCommands(master, "kubeadm init"))
+	}
+	if got := master.Releases("kube-system"); len(got) != 1 || got[0] != "kube-ovn" {
+		t.Errorf("master helm releases = %v, want [kube-ovn]", got)
+	}
+	for _, worker := range []*sshtest.Node{ubuntuWorker, fedoraWorker} {
+		if !worker.Ran("kubeadm join 10.0.0.1:6443") || worker.Ran("--control-plane") {
+			t.Errorf("%s worker did not join as worker: %v", worker.OS.Name, worker.Commands())
+		}
+		if len(worker.Releases("kube-system")) != 0 {
+			t.Errorf("%s worker installed addons", worker.OS.Name)
+		}
+	}
+	if _, err := master.ReadFile("/tmp/k8s-offline-install/cni/multus-cni/multus-daemonset-thick.yml"); err != nil {
+		t.Errorf("master resource package not extracted: %v", err)
+	}
+	for _, n := range []*sshtest.Node{master, ubuntuWorker, fedoraWorker} {
+		if modules, _ := n.ReadFile("/etc/modules-load.d/containerd.conf"); !bytes.Contains(modules, []byte("br_netfilter")) {
+			t.Errorf("%s kernel modules conf = %q", n.OS.Name, modules)
+		}
+	}
+
+	// 再次执行时已完成的步骤全部跳过，不会重复初始化或上传
+	before := len(master.Commands())
+	if err := c.runNode(0); err != nil {
+		t.Fatalf("rerun master: %v", err)
+	}
+	rerun := master.Commands()[before:]
+	for _, cmd := range rerun {
+		if strings.HasPrefix(cmd, "kubeadm init --") || strings.HasPrefix(cmd, "helm install") || strings.HasPrefix(cmd, "kubectl apply") || strings.Contains(cmd, "tar -xzf resources.tar.gz") {
+			t.Errorf("rerun executed %q", cmd)
+		}
+	}
+}
+
+func TestE2EHighAvailability(t *testing.T) {
+	masters := []*sshtest.Node{sshtest.Fedora(), sshtest.Fedora(), sshtest.Ubuntu()}
+	worker := sshtest.OpenEuler()
+	c := newE2ECluster(t, config.InstallModeFull,
+		e2eNode{node: masters[0], master: true, primary: true},
+		e2eNode{node: masters[1], master: true},
+		e2eNode{node: masters[2], master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.HA = config.HAConfig{Enabled: true, VirtualIP: "127.0.0.100/24"}
+
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+
+	if !masters[0].Ran(`--control-plane-endpoint "127.0.0.100:16443"`) {
+		t.Errorf("primary did not init with control plane endpoint: %v", masters[0].Commands())
+	}
+	for i, m := range masters {
+		haproxy, err := m.ReadFile("/etc/haproxy/haproxy.cfg")
+		if err != nil {
+			t.Fatalf("master %d haproxy.cfg: %v", i, err)
+		}
+		for j, ip := range []string{"127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+			if line := fmt.Sprintf("server cp%d %s:6443", j+1, ip); !bytes.Contains(haproxy, []byte(line)) {
+				t.Errorf("master %d haproxy.cfg missing %q", i, line)
+			}
+		}
+
+		keepalived, err := m.ReadFile("/etc/keepalived/keepalived.conf")
+		if err != nil {
+			t.Fatalf("master %d keepalived.conf: %v", i, err)
+		}
+		wantState := "state BACKUP"
+		if i == 0 {
+			wantState = "state MASTER"
+		}
+		if !bytes.Contains(keepalived, []byte(wantState)) || !bytes.Contains(keepalived, []byte("127.0.0.100/24")) {
+			t.Errorf("master %d keepalived.conf = %s", i, keepalived)
+		}
+		if i > 0 && (!m.Ran("--control-plane --certificate-key "+strings.Repeat("0123456789abcdef", 4)) || m.Ran("kubeadm init --")) {
+			t.Errorf("secondary master %d did not join control plane: %v", i, m.Commands())
+		}
+	}
+	if worker.Ran("--control-plane") || !worker.Ran("kubeadm join") {
+		t.Errorf("worker join commands: %v", worker.Commands())
+	}
+	if worker.Ran("haproxy") {
+		t.Error("worker configured haproxy")
+	}
+}
+
+func TestE2EAddonsOnly(t *testing.T) {
+	master := sshtest.Ubuntu()
+	c := newE2ECluster(t, config.InstallModeAddonsOnly, e2eNode{node: master, master: true})
+	c.cfg.Addons.KubeOvn.Enabled = true
+	c.cfg.Addons.Hami.Enabled = true
+
+	// 集群不存在时拒绝安装
+	if err := c.run(t)[0]; err == nil || !strings.Contains(err.Error(), "集群不存在") {
+		t.Fatalf("run without cluster err = %v", err)
+	}
+
+	if err := master.WriteFile("/etc/kubernetes/admin.conf", []byte("kind: Config\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.runNode(0); err != nil {
+		t.Fatal(err)
+	}
+	if master.Ran("kubeadm init --") || master.Ran("kubeadm join") || master.Ran("containerd") {
+		t.Errorf("addons-only ran node installation: %v", master.Commands())
+	}
+	got := master.Releases("kube-system")
+	for _, want := range []string{"kube-ovn", "hami", "hami-webui"} {
+		if !strings.Contains(strings.Join(got, ","), want) {
+			t.Errorf("helm releases = %v, missing %s", got, want)
+		}
+	}
+
+	// release 已存在时跳过，不会重复 helm install
+	if err := c.runNode(0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countCommands(master, "helm install hami "); n != 1 {
+		t.Errorf("helm install hami count = %d, want 1", n)
+	}
+}
+
+func TestE2EPackageCatalog(t *testing.T) {
+	master := sshtest.Ubuntu()
+	c := newE2ECluster(t, config.InstallModeAddonsOnly, e2eNode{node: master, master: true})
+	c.cfg.Addons.Hami.Enabled = true
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{
+		"manifest.yaml": `charts:
+  hami: ["2.7.1", "2.8.0"]
+  hami-webui: ["1.1.0"]
+chart_files:
+  hami: {"2.7.1": "hami-2.7.1.tgz", "2.8.0": "hami-2.8.0.tgz"}
+  hami-webui: {"1.1.0": "hami-webui-1.1.0.tgz"}
+image_groups:
+  hami-images: []
+  hami-webui-images: []
+`,
+		"helm-resource/hami/hami/values.yaml":       "image: hami\n",
+		"helm-resource/hami/hami-webui/values.yaml": "image: hami-webui\n",
+	}))
+	master.WriteFile("/etc/kubernetes/admin.conf", []byte("kind: Config\n"))
+
+	// 未配置版本时使用资源包版本目录中的最新版本，chart 文件名同样来自版本目录
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+	if c.cfg.Addons.Hami.Version != "2.8.0" {
+		t.Errorf("hami version = %s, want 2.8.0 from the package catalog", c.cfg.Addons.Hami.Version)
+	}
+	for _, chart := range []string{"hami/hami/hami-2.8.0.tgz", "hami/hami-webui/hami-webui-1.1.0.tgz"} {
+		if !master.Ran("/tmp/k8s-offline-install/helm-resource/" + chart) {
+			t.Errorf("did not install %s: %v", chart, master.Commands())
+		}
+	}
+}
+
+func TestE2EPreInit(t *testing.T) {
+	master, worker := sshtest.Ubuntu(), sshtest.OpenEuler()
+	c := newE2ECluster(t, config.InstallModePreInit,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.Addons.KubeOvn.Enabled = true
+
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+	for _, n := range []*sshtest.Node{master, worker} {
+		if n.Ran("kubeadm init --") || n.Ran("kubeadm join") || n.Ran("helm install") {
+			t.Errorf("%s pre-init ran cluster commands: %v", n.OS.Name, n.Commands())
+		}
+		if _, err := n.ReadFile("/etc/crictl.yaml"); err != nil {
+			t.Errorf("%s crictl not configured: %v", n.OS.Name, err)
+		}
+	}
+}
+
+func TestE2EStreamUpload(t *testing.T) {
+	master, worker := sshtest.OpenEuler(), sshtest.Ubuntu()
+	c := newE2ECluster(t, config.InstallModeFull,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.Distribution.UploadMode = config.UploadModeStream
+	c.cfg.Addons.KubeOvn.Enabled = true
+	c.cfg.Addons.MultusCNI.Enabled = true
+
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+	// 边传边解压，远端不落地压缩包
+	if _, err := master.ReadFile("/tmp/k8s-offline-install/helm-resource/cni/kube-ovn/values.yaml"); err != nil {
+		t.Errorf("master resources not extracted: %v", err)
+	}
+	if !master.Ran("tar -xz -C") {
+		t.Error("master did not stream the resource package")
+	}
+	for _, n := range []*sshtest.Node{master, worker} {
+		if _, err := n.ReadFile("/tmp/k8s-offline-install/resources.tar.gz"); err == nil {
+			t.Errorf("%s kept resources.tar.gz in stream mode", n.OS.Name)
+		}
+		if n.Ran("tar -xzf resources.tar.gz") {
+			t.Errorf("%s extracted package twice", n.OS.Name)
+		}
+		if _, err := n.ReadFile("/tmp/k8s-offline-install/.extracted_success"); err != nil {
+			t.Errorf("%s success marker missing: %v", n.OS.Name, err)
+		}
+	}
+}
+
+func TestE2EStreamChecksumMismatch(t *testing.T) {
+	// 解压了部分文件后哈希不一致，目标目录不应留下任何内容
+	node := sshtest.Ubuntu()
+	node.Handle(`tar -xz -C (\S+) `, func(n *sshtest.Node, m []string) sshtest.Reply {
+		n.WriteFile(m[1]+"/VERSION", []byte("partial"))
+		return sshtest.Reply{Stdout: strings.Repeat("0", 64) + "  -\n"}
+	})
+	c := newE2ECluster(t, config.InstallModePreInit, e2eNode{node: node, master: true})
+	c.cfg.Distribution.UploadMode = config.UploadModeStream
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{"VERSION": "1.0\n"}))
+	if err := c.run(t)[0]; err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+	for _, p := range []string{"/tmp/k8s-offline-install/VERSION", "/tmp/k8s-offline-install.stream/VERSION"} {
+		if _, err := node.ReadFile(p); err == nil {
+			t.Errorf("%s left behind after checksum mismatch", p)
+		}
+	}
+}
+
+func TestE2ECorruptedUpload(t *testing.T) {
+	const checksum = `^sha256sum (\S+) \| awk '\{print \$1\}'$`
+	pkg := "/tmp/k8s-offline-install/resources.tar.gz"
+
+	// 首次上传的文件在节点上被损坏，重传后校验通过
+	flaky := sshtest.Ubuntu()
+	corrupted := false
+	flaky.Handle(checksum, func(n *sshtest.Node, m []string) sshtest.Reply {
+		if !corrupted {
+			corrupted = true
+			n.WriteFile(m[1], []byte("garbage"))
+		}
+		return sshtest.FileSHA256(n, m)
+	})
+	c := newE2ECluster(t, config.InstallModePreInit, e2eNode{node: flaky, master: true})
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{"VERSION": "1.0\n"}))
+	if err := c.run(t)[0]; err != nil {
+		t.Fatalf("retry after corruption: %v", err)
+	}
+	if got := countCommands(flaky, "sha256sum "+pkg); got != 2 {
+		t.Errorf("remote checksum ran %d times, want 2", got)
+	}
+	if _, err := flaky.ReadFile("/tmp/k8s-offline-install/.extracted_success"); err != nil {
+		t.Errorf("success marker missing: %v", err)
+	}
+
+	// 每次都损坏时放弃并且不解压
+	broken := sshtest.Ubuntu()
+	broken.Handle(checksum, func(n *sshtest.Node, m []string) sshtest.Reply {
+		n.WriteFile(m[1], []byte("garbage"))
+		return sshtest.FileSHA256(n, m)
+	})
+	c = newE2ECluster(t, config.InstallModePreInit, e2eNode{node: broken, master: true})
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{"VERSION": "1.0\n"}))
+	if err := c.run(t)[0]; !errors.Is(err, ErrCorruptedTransfer) {
+		t.Fatalf("err = %v, want ErrCorruptedTransfer", err)
+	}
+	if broken.Ran("tar -xzf") {
+		t.Error("corrupted package was extracted")
+	}
+}
+
+func TestE2EDeltaSync(t *testing.T) {
+	node := sshtest.Ubuntu()
+	c := newE2ECluster(t, config.InstallModePreInit, e2eNode{node: node, master: true})
+	big := strings.Repeat("x", 1<<20)
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{
+		"charts/a.yaml":  "a: 1\n",
+		"charts/b.yaml":  "b: 1\n",
+		"images/big.tar": big,
+	}))
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改 a、删除 b、新增 c，big.tar 不变
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{
+		"charts/a.yaml":  "a: 2\n",
+		"charts/c.yaml":  "c: 1\n",
+		"images/big.tar": big,
+	}))
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+
+	dir := "/tmp/k8s-offline-install/"
+	if got, _ := node.ReadFile(dir + "charts/a.yaml"); string(got) != "a: 2\n" {
+		t.Errorf("charts/a.yaml = %q, want updated content", got)
+	}
+	if _, err := node.ReadFile(dir + "charts/c.yaml"); err != nil {
+		t.Errorf("added file missing: %v", err)
+	}
+	if _, err := node.ReadFile(dir + "charts/b.yaml"); err == nil {
+		t.Error("removed file still on node")
+	}
+	if got, _ := node.ReadFile(dir + "images/big.tar"); string(got) != big {
+		t.Error("unchanged file damaged")
+	}
+	if got := countCommands(node, "cd "+dir[:len(dir)-1]+" && tar -xzf resources.tar.gz"); got != 1 {
+		t.Errorf("full package extracted %d times, want 1", got)
+	}
+	if !node.Ran("tar -xzf resources.delta.tar.gz") {
+		t.Error("delta package not extracted")
+	}
+	for _, stale := range []string{"resources.tar.gz", "resources.delta.tar.gz"} {
+		if _, err := node.ReadFile(dir + stale); err == nil {
+			t.Errorf("%s left on node", stale)
+		}
+	}
+	manifest, _ := node.ReadFile(dir + ".files")
+	if strings.Contains(string(manifest), "charts/b.yaml") || !strings.Contains(string(manifest), "charts/c.yaml") {
+		t.Errorf("remote manifest not updated:\n%s", manifest)
+	}
+}
+
+func TestE2ERoleAwareDistribution(t *testing.T) {
+	master, worker := sshtest.Ubuntu(), sshtest.Fedora()
+	c := newE2ECluster(t, config.InstallModeFull,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.Addons.KubeOvn.Enabled = true
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{
+		"VERSION":                                  "1.0\n",
+		"helm-resource/cni/kube-ovn/values.yaml":   "image: kube-ovn\n",
+		"helm-resource/hami/hami/values.yaml":      "image: hami\n",
+		"helm/amd64/helm.tar.gz":                   "helm",
+		"ha/haproxy/amd64/apt/haproxy.deb":         "haproxy",
+		"k8s/amd64/apt/1-30-14/kubeadm.deb":        "deb",
+		"k8s/amd64/rpm/1-30-14/kubeadm.rpm":        "rpm",
+		"k8s/arm64/rpm/1-30-14/kubeadm.rpm":        "rpm",
+		"docker-ce/containerd/amd64/containerd.gz": "containerd",
+		"docker-ce/containerd/arm64/containerd.gz": "containerd",
+	}))
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+
+	dir := "/tmp/k8s-offline-install/"
+	want := map[*sshtest.Node][]string{
+		master: {"VERSION", "helm-resource/cni/kube-ovn/values.yaml", "helm/amd64/helm.tar.gz", "k8s/amd64/apt/1-30-14/kubeadm.deb", "docker-ce/containerd/amd64/containerd.gz"},
+		worker: {"VERSION", "k8s/amd64/rpm/1-30-14/kubeadm.rpm", "docker-ce/containerd/amd64/containerd.gz"},
+	}
+	unwanted := map[*sshtest.Node][]string{
+		master: {"helm-resource/hami/hami/values.yaml", "ha/haproxy/amd64/apt/haproxy.deb", "k8s/amd64/rpm/1-30-14/kubeadm.rpm", "docker-ce/containerd/arm64/containerd.gz"},
+		worker: {"helm-resource/cni/kube-ovn/values.yaml", "helm/amd64/helm.tar.gz", "k8s/amd64/apt/1-30-14/kubeadm.deb", "k8s/arm64/rpm/1-30-14/kubeadm.rpm"},
+	}
+	for n, files := range want {
+		for _, f := range files {
+			if _, err := n.ReadFile(dir + f); err != nil {
+				t.Errorf("%s missing %s", n.OS.Name, f)
+			}
+		}
+	}
+	for n, files := range unwanted {
+		for _, f := range files {
+			if _, err := n.ReadFile(dir + f); err == nil {
+				t.Errorf("%s received unneeded %s", n.OS.Name, f)
+			}
+		}
+	}
+
+	// 启用 HAMi 后主节点补齐对应 chart，已有文件不重复传输
+	c.cfg.Addons.Hami.Enabled = true
+	c.cfg.InstallMode = config.InstallModeAddonsOnly
+	master.WriteFile("/etc/kubernetes/admin.conf", []byte("kind: Config\n"))
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := master.ReadFile(dir + "helm-resource/hami/hami/values.yaml"); err != nil {
+		t.Error("master missing hami chart after enabling addon")
+	}
+	if _, err := master.ReadFile(dir + "k8s/amd64/apt/1-30-14/kubeadm.deb"); err == nil {
+		t.Error("addons-only master kept node packages it no longer needs")
+	}
+}
+
+func TestE2EPackageManifest(t *testing.T) {
+	master, worker := sshtest.Ubuntu(), sshtest.Fedora()
+	c := newE2ECluster(t, config.InstallModePreInit,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.ResourcePackage = singlePackage(writePackage(t, map[string]string{
+		"manifest.yaml": "name: ubuntu-only\nos_families: [ubuntu]\narches: [amd64]\n",
+		"VERSION":       "1.0\n",
+	}))
+	errs := c.run(t)
+	if errs[0] != nil {
+		t.Fatalf("ubuntu master: %v", errs[0])
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "os family fedora (package has ubuntu)") {
+		t.Fatalf("fedora worker err = %v, want unsupported os family", errs[1])
+	}
+	if worker.Ran("tar -xzf") || worker.Ran("sha256sum") {
+		t.Error("resources were distributed to an unsupported node")
+	}
+
+	pm, err := LoadPackageManifest(c.cfg.ResourcePackage[0].Path)
+	if err != nil || pm == nil || pm.Name != "ubuntu-only" {
+		t.Fatalf("manifest = %+v, %v", pm, err)
+	}
+	if pm, err := LoadPackageManifest(writeResourcePackage(t)); err != nil || pm != nil {
+		t.Errorf("package without manifest = %+v, %v; want nil", pm, err)
+	}
+}
+
+func TestE2EMixedArchPackages(t *testing.T) {
+	master, worker := sshtest.Ubuntu(), sshtest.OpenEuler()
+	c := newE2ECluster(t, config.InstallModePreInit,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	amd64 := writePackage(t, map[string]string{
+		"manifest.yaml": "os_families: [ubuntu]\narches: [amd64]\n",
+		"VERSION":       "amd64\n",
+	})
+	arm64 := writePackage(t, map[string]string{
+		"manifest.yaml": "os_families: [openeuler]\narches: [arm64]\n",
+		"VERSION":       "arm64\n",
+	})
+	c.cfg.ResourcePackage = config.ResourcePackages{{Path: amd64}, {Path: arm64}}
+	if err := config.ApplyDefaultsAndValidate(c.cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPackageSelection(context.Background(), c.cfg); err != nil {
+		t.Fatalf("package selection: %v", err)
+	}
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+	for n, want := range map[*sshtest.Node]string{master: "amd64\n", worker: "arm64\n"} {
+		if got, _ := n.ReadFile("/tmp/k8s-offline-install/VERSION"); string(got) != want {
+			t.Errorf("%s VERSION = %q, want %q", n.OS.Name, got, want)
+		}
+	}
+
+	// 映射中缺少 arm64 时在安装前报出缺少资源包的节点
+	c.cfg.ResourcePackage = config.ResourcePackages{{Path: amd64, Arch: "amd64"}}
+	c.cfg.ResourcePackage = append(c.cfg.ResourcePackage, config.ResourcePackage{Path: arm64, OSFamily: config.OSFamilyUbuntu, Arch: "arm64"})
+	err := CheckPackageSelection(context.Background(), c.cfg)
+	if err == nil || !strings.Contains(err.Error(), c.cfg.Nodes[1].IP+": no resource_package configured for openeuler/arm64") {
+		t.Fatalf("err = %v, want missing package for worker", err)
+	}
+	if strings.Contains(err.Error(), c.cfg.Nodes[0].IP) {
+		t.Errorf("master has a matching package: %v", err)
+	}
+
+	// 只有一个按架构指定的资源包时同样在安装前检查
+	c.cfg.ResourcePackage = config.ResourcePackages{{Path: arm64, Arch: "arm64"}}
+	err = CheckPackageSelection(context.Background(), c.cfg)
+	if err == nil || !strings.Contains(err.Error(), c.cfg.Nodes[0].IP+": no resource_package configured for ubuntu/amd64") {
+		t.Errorf("err = %v, want missing package for master", err)
+	}
+}
+
+func TestE2EReplayTranscript(t *testing.T) {
+	master, worker := sshtest.Ubuntu(), sshtest.Fedora()
+	c := newE2ECluster(t, config.InstallModeFull,
+		e2eNode{node: master, master: true},
+		e2eNode{node: worker},
+	)
+	c.cfg.RecordDir = t.TempDir()
+	for i, err := range c.run(t) {
+		if err != nil {
+			t.Fatalf("record node %d: %v", i, err)
+		}
+	}
+
+	// 节点下线后仅凭会话记录重新执行同一流程
+	for _, srv := range c.servers {
+		srv.Close()
+	}
+	replayCfg := *c.cfg
+	replayCfg.Nodes = slices.Clone(c.cfg.Nodes)
+	replayCfg.ReplayDir, replayCfg.RecordDir = c.cfg.RecordDir, ""
+	replayCfg.JoinCommand = ""
+	replay := &e2eCluster{cfg: &replayCfg}
+	for i, err := range replay.run(t) {
+		if err != nil {
+			t.Fatalf("replay node %d: %v", i, err)
+		}
+	}
+	if replayCfg.JoinCommand == "" {
+		t.Error("replay did not reproduce join command")
+	}
+
+	// 流程与记录不一致时明确报错
+	replayCfg.Addons.MultusCNI.Enabled = true
+	if err := replay.runNode(0); !errors.Is(err, executor.ErrNotRecorded) {
+		t.Errorf("diverged replay err = %v, want ErrNotRecorded", err)
+	}
+}
+
+// publishMirror 将 files 连同记录其哈希的 manifest.yaml 发布为 HTTP 镜像，返回镜像地址与本机目录；
+// keyFile 不为空时附带签名
+func publishMirror(t *testing.T, files map[string]string, keyFile string) (string, string) {
+	t.Helper()
+	root := writeStaging(t, files)
+	pm := config.PackageManifest{
+		Name:       "mirror",
+		OSFamilies: []string{config.OSFamilyUbuntu},
+		Arches:     []string{"amd64"},
+		Files:      map[string]string{},
+	}
+	for name, content := range files {
+		pm.Files[name] = fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+	manifest, err := yaml.Marshal(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := map[string][]byte{config.PackageManifestName: manifest}
+	if keyFile != "" {
+		key, err := loadSigningKey(keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta[config.PackageSignatureName] = signManifest(key, manifest)
+	}
+	for name, data := range meta {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.StripPrefix("/resources", http.FileServer(http.Dir(root))))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/resources/", root
+}
+
+func TestE2EResourceSources(t *testing.T) {
+	files := map[string]string{
+		"VERSION":                           "1.0\n",
+		"k8s/amd64/apt/1-30-14/kubeadm.deb": "deb",
+		"k8s/amd64/rpm/1-30-14/kubeadm.rpm": "rpm",
+	}
+	dir := "/tmp/k8s-offline-install/"
+
+	// 已解压的资源目录：首次也只同步本节点需要的文件，不生成完整资源包
+	node := sshtest.Ubuntu()
+	c := newE2ECluster(t, config.InstallModePreInit, e2eNode{node: node, master: true})
+	staging := writeStaging(t, files)
+	c.cfg.ResourcePackage = singlePackage(staging)
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := node.ReadFile(dir + "k8s/amd64/apt/1-30-14/kubeadm.deb"); string(got) != "deb" {
+		t.Errorf("kubeadm.deb = %q", got)
+	}
+	if _, err := node.ReadFile(dir + "k8s/amd64/rpm/1-30-14/kubeadm.rpm"); err == nil {
+		t.Error("ubuntu node received rpm packages")
+	}
+	if node.Ran("tar -xzf resources.tar.gz") || !node.Ran("tar -xzf resources.delta.tar.gz") {
+		t.Errorf("directory source was not synced file by file: %v", node.Commands())
+	}
+
+	// 目录内容变化后标记失效，只同步变化的文件
+	os.WriteFile(filepath.Join(staging, "VERSION"), []byte("2.0\n"), 0644)
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := node.ReadFile(dir + "VERSION"); string(got) != "2.0\n" {
+		t.Errorf("VERSION = %q after directory update", got)
+	}
+
+	// HTTP 镜像：节点直接下载，签名在本机校验
+	keyFile, pub := writeSigningKey(t)
+	node = sshtest.Ubuntu()
+	c = newE2ECluster(t, config.InstallModePreInit, e2eNode{node: node, master: true})
+	mirror, _ := publishMirror(t, files, keyFile)
+	c.cfg.ResourcePackage = singlePackage(mirror)
+	c.cfg.TrustedKeys = []string{pub}
+	if signed, err := VerifyPackage(c.cfg, c.cfg.ResourcePackage[0].Path); err != nil || !signed {
+		t.Fatalf("VerifyPackage(mirror) = %v, %v", signed, err)
+	}
+	if err := c.run(t)[0]; err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := node.ReadFile(dir + "VERSION"); string(got) != "1.0\n" {
+		t.Errorf("VERSION from mirror = %q", got)
+	}
+	if _, err := node.ReadFile(dir + "k8s/amd64/rpm/1-30-14/kubeadm.rpm"); err == nil {
+		t.Error("ubuntu node downloaded rpm packages")
+	}
+	if marker, _ := node.ReadFile(dir + ".extracted_success"); len(marker) == 0 {
+		t.Error("success marker missing")
+	}
+
+	// 镜像上的文件与 manifest 记录不一致时不替换节点上的文件
+	mirror, root := publishMirror(t, files, "")
+	os.WriteFile(filepath.Join(root, "VERSION"), []byte("tampered\n"), 0644)
+	node = sshtest.Ubuntu()
+	c = newE2ECluster(t, config.InstallModePreInit, e2eNode{node: node, master: true})
+	node.WriteFile(dir+"VERSION", []byte("old\n"))
+	c.cfg.ResourcePackage = singlePackage(mirror)
+	c.cfg.AllowUnsigned = true
+	if err := c.run(t)[0]; err == nil || !strings.Contains(err.Error(), "sha256 mismatch: VERSION") {
+		t.Fatalf("err = %v, want sha256 mismatch", err)
+	}
+	if got, _ := node.ReadFile(dir + "VERSION"); string(got) != "old\n" {
+		t.Errorf("VERSION = %q, tampered file replaced the original", got)
+	}
+}
@@ -0,0 +1,179 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s-offline-tool/pkg/config"
+)
+
+// packageHashes 进程内共享的资源包哈希，所有节点的 Manager 复用同一次计算
+var packageHashes = newFileCache(sourceHash)
+
+// fileCache 按路径、大小与修改时间缓存对文件的计算结果，文件变化后自动重新计算
+type fileCache[T any] struct {
+	compute func(file string, info os.FileInfo) (T, error)
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry[T]
+	pinned  map[string]*cacheEntry[T] // pin 固定的结果，按配置中的路径查找
+}
+
+// cacheEntry 一次计算任务，done 关闭后 value 与 err 可读
+type cacheEntry[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newFileCache[T any](compute func(file string, info os.FileInfo) (T, error)) *fileCache[T] {
+	return &fileCache[T]{compute: compute, entries: make(map[string]*cacheEntry[T]), pinned: make(map[string]*cacheEntry[T])}
+}
+
+// pin 固定 file 的结果，之后不再按大小与修改时间重新计算或读取旁路文件。
+// 签名校验通过的资源包以校验时实际读取的内容为准，校验后文件被替换时分发阶段的 sha256 比对会失败
+func (c *fileCache[T]) pin(file string, value T) {
+	e := &cacheEntry[T]{done: make(chan struct{}), value: value}
+	close(e.done)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned[file] = e
+}
+
+// start 返回文件的计算任务，首次调用时在后台开始计算，调用方可在上传的同时等待结果。
+// 镜像地址无法获取大小与修改时间，info 为 nil，一次运行内只计算一次
+func (c *fileCache[T]) start(file string) *cacheEntry[T] {
+	c.mu.Lock()
+	pinned, ok := c.pinned[file]
+	c.mu.Unlock()
+	if ok {
+		return pinned
+	}
+
+	var info os.FileInfo
+	key := file
+	if !config.IsMirrorURL(file) {
+		var err error
+		if info, err = os.Stat(file); err != nil {
+			e := &cacheEntry[T]{done: make(chan struct{}), err: err}
+			close(e.done)
+			return e
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			abs = file
+		}
+		key = fmt.Sprintf("%s\x00%d\x00%d", abs, info.Size(), info.ModTime().UnixNano())
+		if info.IsDir() {
+			key += "\x00" + dirFingerprint(file)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		return e
+	}
+	e := &cacheEntry[T]{done: make(chan struct{})}
+	c.entries[key] = e
+	go func() {
+		defer close(e.done)
+		e.value, e.err = c.compute(file, info)
+	}()
+	return e
+}
+
+// dirFingerprint 目录下所有文件的路径、大小与修改时间，目录自身的修改时间不反映子目录中文件的变化
+func dirFingerprint(dir string) string {
+	h := sha256.New()
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(h, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// wait 等待计算完成
+func (e *cacheEntry[T]) wait(ctx context.Context) (T, error) {
+	select {
+	case <-e.done:
+		return e.value, e.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// fileHash 优先读取旁路的 .sha256 文件，否则完整计算并尽量写回旁路文件，供下次运行复用
+func fileHash(file string, info os.FileInfo) (string, error) {
+	sidecar := file + ".sha256"
+	if lines, ok := readSidecar(sidecar, info); ok && len(lines) == 1 {
+		fields := strings.Fields(lines[0])
+		if len(fields) > 0 && len(fields[0]) == sha256.Size*2 {
+			if _, err := hex.DecodeString(fields[0]); err == nil {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	writeSidecar(sidecar, info, hash+"  "+filepath.Base(file)+"\n")
+	return hash, nil
+}
+
+// readSidecar 读取旁路文件的内容行；<旁路文件>.meta 中记录的大小与修改时间须与文件一致，否则视为失效
+func readSidecar(sidecar string, info os.FileInfo) ([]string, bool) {
+	meta, err := os.ReadFile(sidecar + ".meta")
+	if err != nil {
+		return nil, false
+	}
+	var size, mtime int64
+	if _, err := fmt.Sscanf(string(meta), "size=%d mtime=%d", &size, &mtime); err != nil {
+		return nil, false
+	}
+	if size != info.Size() || mtime != info.ModTime().UnixNano() {
+		return nil, false
+	}
+	data, err := os.ReadFile(sidecar)
+	if err != nil {
+		return nil, false
+	}
+	content := strings.TrimRight(string(data), "\n")
+	if content == "" {
+		return nil, true
+	}
+	return strings.Split(content, "\n"), true
+}
+
+// writeSidecar 写入旁路文件，文件大小与修改时间单独记录在 <旁路文件>.meta，旁路文件保持原有格式
+// （如 .sha256 可直接用于 sha256sum -c）；资源包所在目录可能只读，写入失败不影响本次安装
+func writeSidecar(sidecar string, info os.FileInfo, content string) {
+	// 先删除旧的校验信息，写入中断时旁路文件视为失效
+	os.Remove(sidecar + ".meta")
+	if err := os.WriteFile(sidecar, []byte(content), 0644); err != nil {
+		return
+	}
+	os.WriteFile(sidecar+".meta", []byte(fmt.Sprintf("size=%d mtime=%d\n", info.Size(), info.ModTime().UnixNano())), 0644)
+}
@@ -0,0 +1,60 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashCacheSidecar(t *testing.T) {
+	pkg := filepath.Join(t.TempDir(), "resources.tar.gz")
+	if err := os.WriteFile(pkg, []byte("package v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("package v1"))
+	want := hex.EncodeToString(sum[:])
+	ctx := context.Background()
+
+	c := newFileCache(fileHash)
+	e := c.start(pkg)
+	if got, err := e.wait(ctx); err != nil || got != want {
+		t.Fatalf("hash = %q, %v; want %q", got, err, want)
+	}
+	if c.start(pkg) != e {
+		t.Error("unchanged package should reuse the same hash task")
+	}
+	// .sha256 保持 sha256sum 格式，缓存校验信息单独存放
+	sidecar, err := os.ReadFile(pkg + ".sha256")
+	if err != nil || string(sidecar) != want+"  resources.tar.gz\n" {
+		t.Fatalf("sidecar = %q, %v", sidecar, err)
+	}
+	if _, err := os.Stat(pkg + ".sha256.meta"); err != nil {
+		t.Fatalf("sidecar meta missing: %v", err)
+	}
+
+	// 新进程（新缓存）直接信任大小与修改时间一致的旁路文件
+	fake := strings.Repeat("ab", sha256.Size)
+	os.WriteFile(pkg+".sha256", []byte(strings.Replace(string(sidecar), want, fake, 1)), 0644)
+	if got, _ := newFileCache(fileHash).start(pkg).wait(ctx); got != fake {
+		t.Errorf("hash = %q, want sidecar value %q", got, fake)
+	}
+
+	// 资源包被替换后旁路文件失效
+	if err := os.WriteFile(pkg, []byte("package v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(pkg, time.Now(), time.Now().Add(time.Hour))
+	sum = sha256.Sum256([]byte("package v2"))
+	if got, _ := c.start(pkg).wait(ctx); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash after change = %q, want %x", got, sum)
+	}
+
+	if _, err := c.start(filepath.Join(t.TempDir(), "missing.tar.gz")).wait(ctx); err == nil {
+		t.Error("missing package should fail")
+	}
+}
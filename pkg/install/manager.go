@@ -1,8 +1,10 @@
 package install
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/executor"
 	"k8s-offline-tool/pkg/install/strategy"
 	"k8s-offline-tool/pkg/runner"
 	"k8s-offline-tool/pkg/ssh"
@@ -25,65 +28,206 @@ import (
 type Manager struct {
 	globalCfg  *config.Config
 	nodeCfg    *config.NodeConfig
-	client     *ssh.Client
+	exec       executor.Executor
+	ctx        context.Context
 	installer  strategy.NodeInstaller
 	context    *strategy.Context
 	output     io.Writer
+	nodeCtx    *ui.NodeContext
 	nodeIndex  int
 	totalNodes int
+	readOnly   bool // 正在执行只读检查，命令可在重连后安全重跑
+
+	distributor *Distributor   // p2p 分发协调器，为空时逐节点直传
+	uploads     *UploadLimiter // 本机上传的带宽与并发限制，为空时不限制
+	components  []string       // 本节点步骤需要的资源包组件，由 GetSteps 汇总
+	// 本节点使用的资源包，检测到系统与架构后由 selectPackage 选定
+	resourcePackage string
+}
+
+// packageHash 返回本地资源包哈希的计算任务，全进程只计算一次
+func (m *Manager) packageHash() *cacheEntry[string] {
+	return packageHashes.start(m.resourcePackage)
 }
 
-func (m *Manager) calculateLocalHash() (string, error) {
-	f, err := os.Open(m.globalCfg.ResourcePackage)
+// localHash 等待本地资源包哈希计算完成
+func (m *Manager) localHash() (string, error) {
+	hash, err := m.packageHash().wait(m.ctx)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to calculate local resource hash: %w", err)
 	}
-	defer f.Close()
+	return hash, nil
+}
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+// NewManager 创建针对特定节点的管理器
+func NewManager(ctx context.Context, globalCfg *config.Config, nodeCfg *config.NodeConfig, nodeIndex int, totalNodes int, output io.Writer) (*Manager, error) {
+	m := NewManagerWithExecutor(ctx, globalCfg, nodeCfg, nil, nodeIndex, totalNodes, output)
+	// 保活 goroutine 会并发写日志，绑定创建时的输出，不读取 Run 中会被替换的 m.output
+	nodeExec, err := newExecutor(ctx, globalCfg, nodeCfg, "", connEventLogger(m.output, nodeCfg.IP))
+	if err != nil {
+		return nil, fmt.Errorf("ssh connection to %s failed: %w", nodeCfg.IP, err)
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+	m.exec = nodeExec
+	return m, nil
 }
 
-// NewManager 创建针对特定节点的管理器
-func NewManager(globalCfg *config.Config, nodeCfg *config.NodeConfig, nodeIndex int, totalNodes int, output io.Writer) (*Manager, error) {
+// NewManagerWithExecutor 使用已建立的执行器创建管理器，便于接入本机或测试替身
+func NewManagerWithExecutor(ctx context.Context, globalCfg *config.Config, nodeCfg *config.NodeConfig, nodeExec executor.Executor, nodeIndex int, totalNodes int, output io.Writer) *Manager {
 	if output == nil {
 		output = os.Stdout
 	}
-	user := globalCfg.User
+	return &Manager{
+		globalCfg:  globalCfg,
+		nodeCfg:    nodeCfg,
+		exec:       nodeExec,
+		ctx:        ctx,
+		output:     output,
+		nodeIndex:  nodeIndex,
+		totalNodes: totalNodes,
+	}
+}
+
+// newExecutor 按配置选择执行方式：回放会话记录、本机执行或 SSH，开启记录时包装为 Recorder。
+// session 为空时使用节点自身的会话记录，其他 Manager 的临时连接使用单独的记录，避免打乱节点记录的顺序
+func newExecutor(ctx context.Context, globalCfg *config.Config, nodeCfg *config.NodeConfig, session string, logf func(format string, args ...any)) (executor.Executor, error) {
+	if globalCfg.ReplayDir != "" {
+		replay, err := executor.LoadReplay(transcriptPath(globalCfg.ReplayDir, nodeCfg.IP, session))
+		if err != nil {
+			return nil, err
+		}
+		return replay, nil
+	}
+	nodeExec, err := dialExecutor(ctx, globalCfg, nodeCfg, logf)
+	if err != nil || globalCfg.RecordDir == "" {
+		return nodeExec, err
+	}
+	recorder, err := executor.NewRecorder(nodeExec, transcriptPath(globalCfg.RecordDir, nodeCfg.IP, session))
+	if err != nil {
+		nodeExec.Close()
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// probeSession 其他节点标注加速卡时建立的临时连接
+const probeSession = "probe"
+
+// transcriptPath 节点会话记录文件路径，session 非空时为 <ip>.<session>.jsonl
+func transcriptPath(dir, ip, session string) string {
+	name := strings.ReplaceAll(ip, ":", "_")
+	if session != "" {
+		name += "." + session
+	}
+	return filepath.Join(dir, name+".jsonl")
+}
+
+// dialExecutor local 节点直接在本机执行，其余通过 SSH
+func dialExecutor(ctx context.Context, globalCfg *config.Config, nodeCfg *config.NodeConfig, logf func(format string, args ...any)) (executor.Executor, error) {
+	if nodeCfg.Local {
+		local, err := executor.NewLocal(time.Duration(globalCfg.CommandTimeoutSeconds) * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	}
+	opts := sshOptions(globalCfg, nodeCfg)
+	opts.Logf = logf
+	client, err := ssh.NewClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// SetDistributor 启用 p2p 资源分发，所有节点的 Manager 共享同一个 Distributor
+func (m *Manager) SetDistributor(d *Distributor) {
+	m.distributor = d
+}
+
+// SetUploadLimiter 所有节点的 Manager 共享同一个 UploadLimiter
+func (m *Manager) SetUploadLimiter(l *UploadLimiter) {
+	m.uploads = l
+}
+
+// connEventLogger 返回将保活失败、重连等连接事件写入节点日志的回调
+func connEventLogger(output io.Writer, ip string) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		fmt.Fprintf(output, "[%s]     ⚡ %s\n", ip, fmt.Sprintf(format, args...))
+	}
+}
+
+// sshOptions 合并全局与节点级 SSH 配置
+func sshOptions(globalCfg *config.Config, nodeCfg *config.NodeConfig) ssh.Options {
 	port := nodeCfg.SSHPort
 	if port == 0 {
 		port = globalCfg.SSHPort
 	}
+	bastions := globalCfg.NodeBastions(nodeCfg)
+	hops := make([]ssh.Hop, 0, len(bastions))
+	for i := range bastions {
+		user := bastions[i].User
+		if user == "" {
+			user = globalCfg.User
+		}
+		hops = append(hops, ssh.Hop{
+			Host: bastions[i].Host,
+			Port: bastions[i].Port,
+			User: user,
+			Auth: sshAuth(globalCfg.BastionAuth(&bastions[i])),
+		})
+	}
+	return ssh.Options{
+		Host: nodeCfg.IP,
+		Port: port,
+		User: globalCfg.User,
+		Auth: sshAuth(globalCfg.NodeAuth(nodeCfg)),
+		HostKey: ssh.HostKeyConfig{
+			Policy:         hostKeyPolicy(globalCfg.HostKeyPolicy),
+			KnownHostsFile: globalCfg.KnownHostsFile,
+		},
+		Bastions: hops,
+		Sudo: ssh.SudoConfig{
+			Enabled:  globalCfg.NeedsSudo(),
+			Password: globalCfg.NodeSudoPassword(nodeCfg),
+		},
+		CommandTimeout:    time.Duration(globalCfg.CommandTimeoutSeconds) * time.Second,
+		KeepaliveInterval: time.Duration(globalCfg.SSHKeepaliveSeconds) * time.Second,
+		ReconnectAttempts: globalCfg.SSHReconnectAttempts,
+	}
+}
 
-	commandTimeout := time.Duration(globalCfg.CommandTimeoutSeconds) * time.Second
-	client, err := ssh.NewClient(nodeCfg.IP, port, user, nodeCfg.Password, commandTimeout)
-	if err != nil {
-		return nil, fmt.Errorf("ssh connection to %s failed: %v", nodeCfg.IP, err)
+// hostKeyPolicy 将配置中的 host_key_policy 转换为 ssh 包的策略，取值已在配置校验时检查
+func hostKeyPolicy(policy string) ssh.HostKeyPolicy {
+	switch policy {
+	case config.HostKeyPolicyStrict:
+		return ssh.HostKeyStrict
+	case config.HostKeyPolicyInsecure:
+		return ssh.HostKeyInsecure
 	}
+	return ssh.HostKeyTOFU
+}
 
-	return &Manager{
-		globalCfg:  globalCfg,
-		nodeCfg:    nodeCfg,
-		client:     client,
-		output:     output,
-		nodeIndex:  nodeIndex,
-		totalNodes: totalNodes,
-	}, nil
+func sshAuth(auth config.SSHAuth) ssh.AuthConfig {
+	return ssh.AuthConfig{
+		Password:             auth.Password,
+		PrivateKey:           auth.PrivateKey,
+		PrivateKeyPassphrase: auth.PrivateKeyPassphrase,
+		SSHAgent:             auth.SSHAgent,
+		SSHAgentSocket:       auth.SSHAgentSocket,
+		KeyboardInteractive:  auth.KeyboardInteractive,
+	}
 }
 
 func (m *Manager) Close() {
-	if m.client != nil {
-		m.client.Close()
+	if m.exec != nil {
+		m.exec.Close()
 	}
 }
 
 func (m *Manager) detectEnv() error {
-	arch, err := m.client.DetectArch()
+	arch, err := executor.DetectArch(m.ctx, m.exec)
 	if err != nil {
-		return fmt.Errorf("failed to detect arch: %v", err)
+		return fmt.Errorf("failed to detect arch: %w", err)
 	}
 
 	// 合并探测命令以减少 RTT
@@ -98,11 +242,13 @@ npu="false"
 if lspci | grep -i "Huawei" >/dev/null 2>&1; then npu="true"; fi
 echo "${name}|${version}|${kernel}|${gpu}|${npu}"
 `
-	out, err := m.client.RunCommand(strings.TrimSpace(probeCmd))
+	res, err := m.exec.Run(executor.WithRetry(m.ctx), strings.TrimSpace(probeCmd))
 	if err != nil {
-		return fmt.Errorf("failed to probe environment: %v", err)
+		return fmt.Errorf("failed to probe environment: %w", err)
 	}
 
+	// 只解析 Stdout，lspci 缺失等告警输出在 Stderr 中
+	out := res.Stdout
 	parts := strings.Split(strings.TrimSpace(out), "|")
 	if len(parts) != 5 {
 		return fmt.Errorf("unexpected probe output: %s", out)
@@ -114,6 +260,10 @@ echo "${name}|${version}|${kernel}|${gpu}|${npu}"
 	hasGPU := parts[3] == "true"
 	hasNPU := parts[4] == "true"
 
+	var onOutput func(string)
+	if m.nodeCtx != nil {
+		onOutput = m.nodeCtx.LogOutput
+	}
 	m.context = &strategy.Context{
 		Cfg:           m.globalCfg,
 		Arch:          arch,
@@ -123,7 +273,9 @@ echo "${name}|${version}|${kernel}|${gpu}|${npu}"
 		HasGPU:        hasGPU,
 		HasNPU:        hasNPU,
 		RemoteTmpDir:  config.RemoteTmpDir,
-		RunCmd:        m.client.RunCommand,
+		Exec:          m.exec,
+		CmdContext:    m.cmdContext,
+		OnOutput:      onOutput,
 	}
 
 	osInfo := strings.ToLower(systemName)
@@ -139,36 +291,274 @@ echo "${name}|${version}|${kernel}|${gpu}|${npu}"
 	return nil
 }
 
-func (m *Manager) distributeResources(nodeCtx *ui.NodeContext) error {
-	localHash, err := m.calculateLocalHash()
-	if err != nil {
-		return fmt.Errorf("failed to calculate local resource hash: %v", err)
+// runCommand 在当前节点执行命令，随 Run 的 ctx 一起取消
+func (m *Manager) runCommand(cmd string) (string, error) {
+	return executor.Output(m.exec.Run(m.cmdContext(), cmd))
+}
+
+// probe 执行只读检查命令，退出码由调用方判断，仅在无法执行时返回错误
+func (m *Manager) probe(cmd string) (*executor.Result, error) {
+	return executor.IgnoreExit(m.exec.Run(m.cmdContext(), cmd))
+}
+
+// cmdContext 只读检查阶段的命令标记为可重试
+func (m *Manager) cmdContext() context.Context {
+	if m.readOnly {
+		return executor.WithRetry(m.ctx)
 	}
+	return m.ctx
+}
+
+// readOnlyCheck 标记步骤的 Check 为只读探测，执行中连接断开时重连后自动重跑
+func (m *Manager) readOnlyCheck(check func() (bool, error)) func() (bool, error) {
+	return func() (bool, error) {
+		m.readOnly = true
+		defer func() { m.readOnly = false }()
+		return check()
+	}
+}
+
+// streamCommand 执行耗时命令，输出实时写入节点日志与 TUI 状态行
+func (m *Manager) streamCommand(cmd string) (string, error) {
+	if m.nodeCtx == nil {
+		return m.runCommand(cmd)
+	}
+	return executor.Output(m.exec.Stream(m.cmdContext(), cmd, m.nodeCtx.LogOutput))
+}
+
+func (m *Manager) distributeResources(nodeCtx *ui.NodeContext) error {
+	// 哈希与文件清单在后台计算，与上传同时进行，直到需要比对时才等待结果
+	m.packageHash()
+	packageFiles.start(m.resourcePackage)
 
 	remotePkgPath := path.Join(m.context.RemoteTmpDir, "resources.tar.gz")
 	remoteMarkerPath := path.Join(m.context.RemoteTmpDir, ".extracted_success")
 
-	// 上传压缩包
-	fileName := filepath.Base(m.globalCfg.ResourcePackage)
+	// p2p 模式下优先从已就绪节点拉取，失败时回退到本机直传
+	var src *peerSource
+	var err error
+	pulled := false
+	if m.distributor != nil {
+		if src, err = m.distributor.acquire(m.ctx, m.resourcePackage); err != nil {
+			return err
+		}
+		defer func() {
+			m.distributor.release(m.resourcePackage, src, src == nil || pulled)
+		}()
+	}
+	if src != nil {
+		localHash, err := m.localHash()
+		if err != nil {
+			return err
+		}
+		if pullErr := m.pullFromPeer(nodeCtx, src, remotePkgPath, localHash); pullErr != nil {
+			if m.ctx.Err() != nil {
+				return m.ctx.Err()
+			}
+			fmt.Fprintf(m.output, "[%s]     ⚠ 从节点 %s 拉取资源失败，改为本机直传: %v\n", m.nodeCfg.IP, src.IP, pullErr)
+		} else {
+			pulled = true
+		}
+	}
+	// 直传模式下节点已有上次分发的文件时只同步变化的文件；p2p 模式需要完整资源包作为中继来源
+	synced := false
+	if m.distributor == nil {
+		if synced, err = m.syncDelta(nodeCtx); err != nil {
+			return err
+		}
+	}
+	streamed := false
+	if !pulled && !synced {
+		pkg := m.resourcePackage
+		if m.globalCfg.Distribution.UploadMode == config.UploadModeStream {
+			streamed = true
+			err = m.streamPackage(nodeCtx, pkg, m.localHash)
+		} else {
+			err = m.uploadVerified(nodeCtx, pkg, remotePkgPath, m.localHash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. 远端解压；流式传输或增量同步时已在传输过程中解压
+	if !streamed && !synced {
+		nodeCtx.UpdateResourceProgress("正在远端解压资源...")
+		extractCmd := fmt.Sprintf("cd %s && tar -xzf resources.tar.gz", m.context.RemoteTmpDir)
+		if _, err := m.runCommand(extractCmd); err != nil {
+			return fmt.Errorf("extract resource package failed: %w", err)
+		}
+	}
+
+	// 4. 记录文件清单并写入标记位
+	if err := m.writeRemoteFileList(); err != nil {
+		return err
+	}
+	marker, err := m.distributionMarker()
+	if err != nil {
+		return err
+	}
+	markCmd := fmt.Sprintf("echo '%s' > %s", marker, remoteMarkerPath)
+	if _, err := m.runCommand(markCmd); err != nil {
+		return fmt.Errorf("failed to write success marker: %w", err)
+	}
+
+	// 5. p2p 模式下本节点成为新的来源，需在 release 之前登记
+	if m.distributor != nil {
+		if relay, err := m.startRelay(remotePkgPath); err != nil {
+			fmt.Fprintf(m.output, "[%s]     ⚠ 启动资源中继失败，本节点不参与分发: %v\n", m.nodeCfg.IP, err)
+		} else {
+			m.distributor.addSource(m.resourcePackage, relay)
+		}
+	}
+
+	return nil
+}
+
+// uploadAttempts 远端校验不一致时最多上传的次数
+const uploadAttempts = 3
+
+// ErrCorruptedTransfer 多次上传后远端资源包的 sha256 仍与本机不一致
+var ErrCorruptedTransfer = errors.New("corrupted transfer")
+
+// uploadVerified 上传压缩包后在节点上计算 sha256，与本机一致才允许解压；不一致时删除远端文件重新上传
+func (m *Manager) uploadVerified(nodeCtx *ui.NodeContext, localPath, remotePkgPath string, localHashFn func() (string, error)) error {
+	var remoteHash string
+	for attempt := 1; attempt <= uploadAttempts; attempt++ {
+		if err := m.uploadPackage(nodeCtx, localPath, remotePkgPath); err != nil {
+			return err
+		}
+		localHash, err := localHashFn()
+		if err != nil {
+			return err
+		}
+		nodeCtx.UpdateResourceProgress("正在校验远端资源包...")
+		out, err := m.runCommand(fmt.Sprintf("sha256sum %s | awk '{print $1}'", remotePkgPath))
+		if err != nil {
+			return fmt.Errorf("failed to checksum remote resource package: %w", err)
+		}
+		if remoteHash = strings.TrimSpace(out); remoteHash == localHash {
+			return nil
+		}
+		// 续传会复用远端已有内容，重传前必须删除损坏的文件
+		if _, err := m.runCommand(fmt.Sprintf("rm -f %s", remotePkgPath)); err != nil {
+			return fmt.Errorf("failed to remove corrupted resource package: %w", err)
+		}
+		if attempt < uploadAttempts {
+			fmt.Fprintf(m.output, "[%s]     ⚠ 远端资源包校验失败（sha256 %s），重新上传 (%d/%d)\n", m.nodeCfg.IP, remoteHash, attempt+1, uploadAttempts)
+		}
+	}
+	localHash, _ := localHashFn()
+	return fmt.Errorf("%w: resource package on %s has sha256 %s, expected %s after %d attempts", ErrCorruptedTransfer, m.nodeCfg.IP, remoteHash, localHash, uploadAttempts)
+}
+
+// uploadPackage 从本机上传压缩包到节点
+func (m *Manager) uploadPackage(nodeCtx *ui.NodeContext, localPath, remotePkgPath string) error {
+	f, totalSize, err := openResourcePackage(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	uploadCtx, release, err := m.uploads.acquire(m.ctx, func() {
+		nodeCtx.UpdateResourceProgress("等待上传槽位...")
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	onProgress := uploadProgress(nodeCtx, filepath.Base(localPath))
+	if err := m.exec.PutFile(uploadCtx, remotePkgPath, f, totalSize, onProgress); err != nil {
+		return fmt.Errorf("upload resource package failed: %w", err)
+	}
+	return nil
+}
 
-	f, err := os.Open(m.globalCfg.ResourcePackage)
+// streamPackage 将压缩包经 SSH 标准输入直接送入远端 tar 解压，节点上不落地压缩包。
+// 两端分别计算实际传输字节的 sha256，与本地哈希一致才算成功。
+func (m *Manager) streamPackage(nodeCtx *ui.NodeContext, localPath string, localHashFn func() (string, error)) error {
+	f, totalSize, err := openResourcePackage(localPath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	fileInfo, err := f.Stat()
+	uploadCtx, release, err := m.uploads.acquire(m.ctx, func() {
+		nodeCtx.UpdateResourceProgress("等待上传槽位...")
+	})
 	if err != nil {
 		return err
 	}
-	totalSize := fileInfo.Size()
+	defer release()
 
-	startTime := time.Now()
-	lastUpdate := time.Now()
+	onProgress := uploadProgress(nodeCtx, filepath.Base(localPath))
+	sent := sha256.New()
+	reader := &executor.ProgressReader{
+		Reader: io.TeeReader(f, sent),
+		Total:  totalSize,
+		OnProgress: func(current, total int64) {
+			onProgress(executor.UploadProgress{Current: current, Total: total})
+		},
+	}
+
+	// tee 将输入同时送给 tar 与 sha256sum，tar 的输出转到 stderr，stdout 只有远端哈希。
+	// 先解压到同级的临时目录，哈希一致后才移入目标目录，失败时目标目录保持原样
+	dir := path.Clean(m.context.RemoteTmpDir)
+	staging := dir + ".stream"
+	cmd := fmt.Sprintf(`rm -rf %[2]s && mkdir -p %[1]s %[2]s && bash -o pipefail -c '{ tee /dev/fd/3 | tar -xz -C %[2]s >&2; } 3>&1 | sha256sum'`, dir, staging)
+	res, err := m.exec.RunStdin(uploadCtx, cmd, reader)
+	if err != nil {
+		m.runCommand(fmt.Sprintf("rm -rf %s", staging))
+		return fmt.Errorf("stream resource package failed: %w", err)
+	}
 
-	onProgress := func(current, total int64) {
+	localHash, err := localHashFn()
+	if err != nil {
+		m.runCommand(fmt.Sprintf("rm -rf %s", staging))
+		return err
+	}
+	sentHash := hex.EncodeToString(sent.Sum(nil))
+	remoteHash := ""
+	if fields := strings.Fields(res.Stdout); len(fields) > 0 {
+		remoteHash = fields[0]
+	}
+	if sentHash != localHash || remoteHash != localHash {
+		m.runCommand(fmt.Sprintf("rm -rf %s", staging))
+		return fmt.Errorf("streamed resource package checksum mismatch: local %s, sent %s, remote %s", localHash, sentHash, remoteHash)
+	}
+	// 以硬链接移入目标目录并覆盖同名文件，增量同步时目录中的其他文件保持不变
+	if _, err := m.runCommand(fmt.Sprintf("cp -al --remove-destination %[1]s/. %[2]s/ && rm -rf %[1]s", staging, dir)); err != nil {
+		return fmt.Errorf("failed to move streamed resources into place: %w", err)
+	}
+	return nil
+}
+
+func openResourcePackage(pkgPath string) (*os.File, int64, error) {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fileInfo.Size(), nil
+}
+
+// uploadProgress 返回将传输进度写入 TUI 状态行的回调，每 500ms 刷新一次
+func uploadProgress(nodeCtx *ui.NodeContext, fileName string) func(executor.UploadProgress) {
+	var startTime, lastUpdate time.Time
+	var resumed int64
+	return func(p executor.UploadProgress) {
 		now := time.Now()
-		if now.Sub(lastUpdate) < 500*time.Millisecond && current < total {
+		if startTime.IsZero() || p.Resumed != resumed {
+			// 开始传输或重连续传后重新计算速度
+			resumed = p.Resumed
+			startTime = now
+		}
+		if now.Sub(lastUpdate) < 500*time.Millisecond && p.Current < p.Total {
 			return
 		}
 		lastUpdate = now
@@ -177,47 +567,36 @@ func (m *Manager) distributeResources(nodeCtx *ui.NodeContext) error {
 		if elapsed <= 0 {
 			elapsed = 0.1
 		}
-		speed := float64(current) / elapsed / 1024 / 1024 // MB/s
-		percent := float64(current) / float64(total) * 100
+		speed := float64(p.Current-p.Resumed) / elapsed / 1024 / 1024 // MB/s
+		percent := float64(p.Current) / float64(p.Total) * 100
 
 		progressStr := fmt.Sprintf("%s %.2f%% (%.1f/%.1f MB) %.2f MB/s",
-			fileName, percent, float64(current)/1024/1024, float64(total)/1024/1024, speed)
+			fileName, percent, float64(p.Current)/1024/1024, float64(p.Total)/1024/1024, speed)
+		if p.Resumed > 0 {
+			progressStr += fmt.Sprintf(" [续传自 %.1f MB]", float64(p.Resumed)/1024/1024)
+		}
 
 		nodeCtx.UpdateResourceProgress(progressStr)
 	}
-
-	if err := m.client.WriteFileWithProgress(remotePkgPath, f, totalSize, onProgress); err != nil {
-		return fmt.Errorf("upload resource package failed: %v", err)
-	}
-
-	// 3. 远端清理并解压
-	nodeCtx.UpdateResourceProgress("正在远端解压资源...")
-	extractCmd := fmt.Sprintf("cd %s && tar -xzf resources.tar.gz", m.context.RemoteTmpDir)
-	if _, err := m.client.RunCommand(extractCmd); err != nil {
-		return fmt.Errorf("extract resource package failed: %v", err)
-	}
-
-	// 4. 写入标记位
-	markCmd := fmt.Sprintf("echo '%s' > %s", localHash, remoteMarkerPath)
-	if _, err := m.client.RunCommand(markCmd); err != nil {
-		return fmt.Errorf("failed to write success marker: %v", err)
-	}
-
-	return nil
 }
 
-func (m *Manager) Run(nodeCtx *ui.NodeContext, dryRun bool) (err error) {
+func (m *Manager) Run(ctx context.Context, nodeCtx *ui.NodeContext, dryRun bool) (err error) {
 	start := time.Now()
 	defer func() {
 		nodeCtx.Mu.Lock()
 		if err != nil && nodeCtx.Err == nil {
 			nodeCtx.Err = err
+			if errors.Is(err, context.Canceled) {
+				nodeCtx.Err = ui.ErrCancelled
+			}
 		}
 		nodeCtx.Mu.Unlock()
 		nodeCtx.Finish(err == nil, time.Since(start))
 	}()
 
 	m.output = nodeCtx // Ensure output goes to NodeContext
+	m.nodeCtx = nodeCtx
+	m.ctx = ctx
 
 	if err = m.detectEnv(); err != nil {
 		return err
@@ -229,15 +608,25 @@ func (m *Manager) Run(nodeCtx *ui.NodeContext, dryRun bool) (err error) {
 	if m.nodeCfg.IsMaster {
 		role = "master"
 	}
-	fmt.Fprintf(nodeCtx.LogBuffer, "%s(%d/%d %s) 检测到 %s %s | KernelVersion: %s | Arch: %s | GPU: %v | NPU: %v\n", prefix,
+	fmt.Fprintf(nodeCtx, "%s(%d/%d %s) 检测到 %s %s | KernelVersion: %s | Arch: %s | GPU: %v | NPU: %v\n", prefix,
 		m.nodeIndex, m.totalNodes, role, m.context.SystemName, m.context.SystemVersion, m.context.KernelVersion, m.context.Arch, m.context.HasGPU, m.context.HasNPU)
 
-	hasCluster, err := m.checkClusterStatus()
+	if err := m.selectPackage(); err != nil {
+		return err
+	}
+
+	hasCluster, err := m.readOnlyCheck(m.checkClusterStatus)()
+	if err != nil {
+		return fmt.Errorf("failed to check cluster status: %w", err)
+	}
 	if m.globalCfg.InstallMode == config.InstallModeAddonsOnly && !hasCluster {
 		return fmt.Errorf("集群不存在，无法安装插件")
 	}
 
 	steps := m.GetSteps(nodeCtx)
+	for i := range steps {
+		steps[i].Check = m.readOnlyCheck(steps[i].Check)
+	}
 
 	// Update total steps in context if needed
 	nodeCtx.Mu.Lock()
@@ -248,7 +637,7 @@ func (m *Manager) Run(nodeCtx *ui.NodeContext, dryRun bool) (err error) {
 	nodeCtx.Mu.Unlock()
 
 	// 调用 Runner，传入前缀
-	return runner.RunPipeline(steps, prefix, nodeCtx, dryRun)
+	return runner.RunPipeline(ctx, steps, prefix, nodeCtx, dryRun)
 }
 
 func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
@@ -256,14 +645,20 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 		{
 			Name: "分发离线资源",
 			Check: func() (bool, error) {
-				localHash, err := m.calculateLocalHash()
+				remoteMarkerPath := path.Join(m.context.RemoteTmpDir, ".extracted_success")
+				res, err := m.probe(fmt.Sprintf("cat %s", remoteMarkerPath))
 				if err != nil {
 					return false, err
 				}
-				remoteMarkerPath := path.Join(m.context.RemoteTmpDir, ".extracted_success")
-				checkCmd := fmt.Sprintf("cat %s 2>/dev/null || echo 'MISSING'", remoteMarkerPath)
-				remoteContent, _ := m.client.RunCommand(checkCmd)
-				return strings.TrimSpace(remoteContent) == localHash, nil
+				// 标记文件不存在时 cat 退出码非 0，需要重新分发，此时无需等待本地哈希
+				if res.ExitCode != 0 {
+					return false, nil
+				}
+				marker, err := m.distributionMarker()
+				if err != nil {
+					return false, err
+				}
+				return strings.TrimSpace(res.Stdout) == marker, nil
 			},
 			Action: func() error {
 				return m.distributeResources(nodeCtx)
@@ -299,26 +694,30 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 				Action: m.installer.ConfigureSysctl,
 			},
 			runner.Step{
-				Name: "安装常用工具",
+				Name:      "安装常用工具",
+				Resources: []string{componentCommonTools},
 				Check: func() (bool, error) {
 					return m.installer.CheckCommonTools()
 				},
 				Action: m.installer.InstallCommonTools,
 			},
 			runner.Step{
-				Name:   "安装 Docker 软件包",
-				Check:  m.installer.CheckDockerBinary,
-				Action: m.installer.InstallDockerBinary,
+				Name:      "安装 Docker 软件包",
+				Resources: []string{componentDocker},
+				Check:     m.installer.CheckDockerBinary,
+				Action:    m.installer.InstallDockerBinary,
 			},
 			runner.Step{
-				Name:   "安装 Containerd 软件包",
-				Check:  m.installer.CheckContainerdBinary,
-				Action: m.installer.InstallContainerdBinary,
+				Name:      "安装 Containerd 软件包",
+				Resources: []string{componentContainerd},
+				Check:     m.installer.CheckContainerdBinary,
+				Action:    m.installer.InstallContainerdBinary,
 			},
 			runner.Step{
-				Name:   "安装 Runc 软件包",
-				Check:  m.installer.CheckRuncBinary,
-				Action: m.installer.InstallRuncBinary,
+				Name:      "安装 Runc 软件包",
+				Resources: []string{componentRunc},
+				Check:     m.installer.CheckRuncBinary,
+				Action:    m.installer.InstallRuncBinary,
 			},
 			runner.Step{
 				Name:   "配置cgroup 并启动 Containerd",
@@ -331,16 +730,18 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 				Action: m.installer.ConfigureCrictl,
 			},
 			runner.Step{
-				Name:   "安装 Nerdctl",
-				Check:  m.installer.CheckNerdctl,
-				Action: m.installer.InstallNerdctl,
+				Name:      "安装 Nerdctl",
+				Resources: []string{componentNerdctl},
+				Check:     m.installer.CheckNerdctl,
+				Action:    m.installer.InstallNerdctl,
 			},
 		)
 
 		if m.isPrimaryExecutionNode() {
 			steps = append(steps,
 				runner.Step{
-					Name: "安装 Helm",
+					Name:      "安装 Helm",
+					Resources: []string{componentHelm},
 					Check: func() (bool, error) {
 						return m.checkHelmInstalled()
 					},
@@ -364,7 +765,8 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 					},
 				},
 				runner.Step{
-					Name: "安装 HAProxy",
+					Name:      "安装 HAProxy",
+					Resources: []string{componentHAProxy},
 					Check: func() (bool, error) {
 						return m.installer.CheckHAProxy()
 					},
@@ -382,7 +784,8 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 					},
 				},
 				runner.Step{
-					Name: "安装 Keepalived",
+					Name:      "安装 Keepalived",
+					Resources: []string{componentKeepalived},
 					Check: func() (bool, error) {
 						return m.installer.CheckKeepalived()
 					},
@@ -415,20 +818,30 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 
 		// 加速卡运行时
 		if m.context.HasGPU || m.context.HasNPU {
+			// nvidia-container-toolkit 位于常用工具目录，Ascend 运行时单独分发
+			var resources []string
+			if m.context.HasGPU {
+				resources = append(resources, componentCommonTools)
+			}
+			if m.context.HasNPU {
+				resources = append(resources, componentAscendRuntime)
+			}
 			steps = append(steps,
 				runner.Step{
-					Name:   "配置加速卡运行时",
-					Check:  m.installer.CheckAcceleratorConfig,
-					Action: m.installer.ConfigureAccelerator,
+					Name:      "配置加速卡运行时",
+					Resources: resources,
+					Check:     m.installer.CheckAcceleratorConfig,
+					Action:    m.installer.ConfigureAccelerator,
 				},
 			)
 		}
 
 		steps = append(steps,
 			runner.Step{
-				Name:   "安装 Kubernetes 组件",
-				Check:  m.installer.CheckK8sComponents,
-				Action: m.installer.InstallK8sComponents,
+				Name:      "安装 Kubernetes 组件",
+				Resources: []string{componentKubernetes},
+				Check:     m.installer.CheckK8sComponents,
+				Action:    m.installer.InstallK8sComponents,
 			})
 	}
 
@@ -447,6 +860,7 @@ func (m *Manager) GetSteps(nodeCtx *ui.NodeContext) []runner.Step {
 		steps = append(steps, m.addonSteps()...)
 	}
 
+	m.components = requiredComponents(steps)
 	return steps
 }
 
@@ -660,16 +1074,10 @@ func (m *Manager) addonSteps() []runner.Step {
 	// 1. Kube-OVN CNI (Full or AddonsOnly)
 	if m.globalCfg.Addons.KubeOvn.Enabled {
 		steps = append(steps, runner.Step{
-			Name: "部署 Kube-OVN CNI",
+			Name:      "部署 Kube-OVN CNI",
+			Resources: []string{componentKubeOvn},
 			Check: func() (bool, error) {
-				out, err := m.context.RunCmd("test -e /etc/cni/net.d/01-kube-ovn.conflist && echo EXISTS || echo MISSING")
-				if err != nil {
-					return true, err
-				}
-				if strings.TrimSpace(out) == "EXISTS" {
-					return true, nil
-				}
-				return false, nil
+				return m.context.Test("-e /etc/cni/net.d/01-kube-ovn.conflist")
 			},
 			Action: m.deployKubeOvn,
 		})
@@ -678,16 +1086,10 @@ func (m *Manager) addonSteps() []runner.Step {
 	// 2. Multus CNI (Full or AddonsOnly)
 	if m.globalCfg.Addons.MultusCNI.Enabled {
 		steps = append(steps, runner.Step{
-			Name: "部署 Multus CNI",
+			Name:      "部署 Multus CNI",
+			Resources: []string{componentMultus},
 			Check: func() (bool, error) {
-				out, err := m.context.RunCmd("test -e /etc/cni/net.d/00-multus.conf && echo EXISTS || echo MISSING")
-				if err != nil {
-					return true, err
-				}
-				if strings.TrimSpace(out) == "EXISTS" {
-					return true, nil
-				}
-				return false, nil
+				return m.context.Test("-e /etc/cni/net.d/00-multus.conf")
 			},
 			Action: m.deployMultusCNI,
 		})
@@ -696,13 +1098,10 @@ func (m *Manager) addonSteps() []runner.Step {
 	// 3. kube-prometheus-stack (AddonsOnly only)
 	if mode == config.InstallModeAddonsOnly && m.globalCfg.Addons.KubePrometheus.Enabled {
 		steps = append(steps, runner.Step{
-			Name: "部署 kube-prometheus-stack",
+			Name:      "部署 kube-prometheus-stack",
+			Resources: []string{componentPrometheus},
 			Check: func() (bool, error) {
-				out, err := m.context.RunCmd("helm -n monitoring list -q | grep -w '^kube-prometheus-stack$' || true")
-				if err != nil {
-					return false, err
-				}
-				return strings.TrimSpace(out) == "kube-prometheus-stack", nil
+				return m.helmReleaseExists("monitoring", "kube-prometheus-stack")
 			},
 			Action: m.deployKubePrometheusStack,
 		})
@@ -711,13 +1110,10 @@ func (m *Manager) addonSteps() []runner.Step {
 	// 4. HAMI (AddonsOnly only)
 	if mode == config.InstallModeAddonsOnly && m.globalCfg.Addons.Hami.Enabled {
 		steps = append(steps, runner.Step{
-			Name: "部署 HAMI",
+			Name:      "部署 HAMI",
+			Resources: []string{componentHami},
 			Check: func() (bool, error) {
-				out, err := m.context.RunCmd("helm -n kube-system list -q | grep -w '^hami$' || true")
-				if err != nil {
-					return false, err
-				}
-				return strings.TrimSpace(out) == "hami", nil
+				return m.helmReleaseExists("kube-system", "hami")
 			},
 			Action: m.deployHami,
 		})
@@ -725,13 +1121,10 @@ func (m *Manager) addonSteps() []runner.Step {
 		// TODO: HAMI-UI 官方前端arm镜像有bug先不安装
 		if m.context.Arch != "arm64" {
 			steps = append(steps, runner.Step{
-				Name: "部署 HAMI-WebUI",
+				Name:      "部署 HAMI-WebUI",
+				Resources: []string{componentHamiWebUI},
 				Check: func() (bool, error) {
-					out, err := m.context.RunCmd("helm -n kube-system list -q | grep -w '^hami-webui$' || true")
-					if err != nil {
-						return false, err
-					}
-					return strings.TrimSpace(out) == "hami-webui", nil
+					return m.helmReleaseExists("kube-system", "hami-webui")
 				},
 				Action: m.deployHamiWebUI,
 			})
@@ -742,14 +1135,21 @@ func (m *Manager) addonSteps() []runner.Step {
 	// 仅在 addons-only 模式、已成功部署 HAMi 且集群中存在 Ascend 节点时才会触发。
 	if mode == config.InstallModeAddonsOnly && m.globalCfg.Addons.Hami.Enabled {
 		steps = append(steps, runner.Step{
-			Name: "部署 ascend-vnpu-device-plugin",
+			Name:      "部署 ascend-vnpu-device-plugin",
+			Resources: []string{componentAscendVNPU},
 			Check: func() (bool, error) {
-				hamiOut, _ := m.context.RunCmd("helm -n kube-system list -q | grep -w '^hami$' || true")
-				if strings.TrimSpace(hamiOut) != "hami" {
+				hamiExists, err := m.helmReleaseExists("kube-system", "hami")
+				if err != nil {
+					return false, err
+				}
+				if !hamiExists {
 					return true, nil // Skip if hami not found
 				}
 
-				npuOut, _ := m.context.RunCmd("kubectl get node -l ascend=on -o name")
+				npuOut, err := m.context.RunCmd("kubectl get node -l ascend=on -o name")
+				if err != nil {
+					return false, err
+				}
 				if strings.TrimSpace(npuOut) == "" {
 					return true, nil // Skip if no ascend nodes
 				}
@@ -767,6 +1167,21 @@ func (m *Manager) addonSteps() []runner.Step {
 	return steps
 }
 
+// helmReleaseExists 精确判断 release 是否存在：helm 未安装 (127) 视为不存在，其他失败如实返回
+func (m *Manager) helmReleaseExists(namespace, release string) (bool, error) {
+	res, err := m.probe(fmt.Sprintf("helm -n %s list -q", namespace))
+	if err != nil {
+		return false, err
+	}
+	switch res.ExitCode {
+	case 0:
+		return slices.Contains(strings.Fields(res.Stdout), release), nil
+	case 127:
+		return false, nil
+	}
+	return false, &executor.ExitError{Result: res}
+}
+
 func (m *Manager) registryHost() (string, bool) {
 	if strings.TrimSpace(m.globalCfg.Registry.Endpoint) == "" {
 		return "", false
@@ -782,17 +1197,26 @@ func (m *Manager) ensureAdminConf() error {
 	return nil
 }
 
-func (m *Manager) deployHelmAddon(name, groupKey, relativePath, chartName, namespace string) error {
+// deployHelmAddon 安装资源包中的 chart，chart 文件名取自版本目录，version 为空时使用目录中的最新版本
+func (m *Manager) deployHelmAddon(name, groupKey, relativePath, version, namespace string) error {
 	if err := m.ensureAdminConf(); err != nil {
 		return err
 	}
+	catalog, err := m.catalog()
+	if err != nil {
+		return err
+	}
+	chartName, err := catalog.ChartFile(name, version)
+	if err != nil {
+		return err
+	}
 	chartPath := path.Join(m.context.RemoteTmpDir, "helm-resource", relativePath, chartName)
 	valuesPath := path.Join(m.context.RemoteTmpDir, "helm-resource", relativePath, "values.yaml")
-	if err := m.rewriteHelmValuesFile(groupKey, valuesPath); err != nil {
+	if err := m.rewriteHelmValuesFile(catalog.ImageGroups[groupKey], valuesPath); err != nil {
 		return err
 	}
 	cmd := fmt.Sprintf("helm install %s %s -n %s -f %s --create-namespace", name, chartPath, namespace, valuesPath)
-	_, err := m.context.RunCmd(cmd)
+	_, err = m.context.StreamCmd(cmd)
 	return err
 }
 
@@ -801,7 +1225,7 @@ func (m *Manager) deployKubeOvn() error {
 	if err != nil {
 		return err
 	}
-	return m.deployHelmAddon("kube-ovn", "kube-ovn-images", path.Join("cni", "kube-ovn"), config.DefaultKubeOvnChart, "kube-system")
+	return m.deployHelmAddon("kube-ovn", "kube-ovn-images", path.Join("cni", "kube-ovn"), m.globalCfg.Addons.KubeOvn.Version, "kube-system")
 }
 
 func (m *Manager) deployMultusCNI() error {
@@ -822,7 +1246,7 @@ func (m *Manager) deployMultusCNI() error {
 }
 
 func (m *Manager) deployKubePrometheusStack() error {
-	return m.deployHelmAddon("kube-prometheus-stack", "kube-prometheus-stack-images", "kube-prometheus-stack", config.DefaultKubePrometheusStackChart, "monitoring")
+	return m.deployHelmAddon("kube-prometheus-stack", "kube-prometheus-stack-images", "kube-prometheus-stack", m.globalCfg.Addons.KubePrometheus.Version, "monitoring")
 }
 
 func (m *Manager) deployHami() error {
@@ -833,7 +1257,7 @@ func (m *Manager) deployHami() error {
 	// 1. 遍历所有节点并打标
 	hasAscend, err := m.labelAcceleratorNodes()
 	if err != nil {
-		return fmt.Errorf("failed to label accelerator nodes: %v", err)
+		return fmt.Errorf("failed to label accelerator nodes: %w", err)
 	}
 
 	// 2. 修改 values.yaml
@@ -842,11 +1266,11 @@ func (m *Manager) deployHami() error {
 		// 使用更精确的 sed 命令：匹配以 'ascend:' 开头的行，并在该行到后续 'enabled:' 出现的范围内，将 'enabled: false' 替换为 'enabled: true'
 		cmd := fmt.Sprintf("sed -i '/ascend:/,/enabled:/ s/enabled: false/enabled: true/' %s", valuesPath)
 		if _, err := m.context.RunCmd(cmd); err != nil {
-			return fmt.Errorf("failed to enable ascend in hami values.yaml: %v", err)
+			return fmt.Errorf("failed to enable ascend in hami values.yaml: %w", err)
 		}
 	}
 
-	return m.deployHelmAddon("hami", "hami-images", path.Join("hami", "hami"), config.DefaultHamiChart, "kube-system")
+	return m.deployHelmAddon("hami", "hami-images", path.Join("hami", "hami"), m.globalCfg.Addons.Hami.Version, "kube-system")
 }
 
 func (m *Manager) labelAcceleratorNodes() (bool, error) {
@@ -900,26 +1324,23 @@ func (m *Manager) probeNodeAccelerators(node config.NodeConfig) (bool, bool, err
 		return m.context.HasGPU, m.context.HasNPU, nil
 	}
 
-	// 否则需要建立临时 SSH 连接
-	port := node.SSHPort
-	if port == 0 {
-		port = m.globalCfg.SSHPort
-	}
-	client, err := ssh.NewClient(node.IP, port, m.globalCfg.User, node.Password, time.Duration(m.globalCfg.CommandTimeoutSeconds)*time.Second)
+	// 否则需要建立临时连接，会话记录与该节点自身的记录分开
+	nodeExec, err := newExecutor(m.ctx, m.globalCfg, &node, probeSession, nil)
 	if err != nil {
 		return false, false, err
 	}
-	defer client.Close()
+	defer nodeExec.Close()
 
 	probeCmd := `
 gpu="false"; if lspci | grep -i nvidia >/dev/null 2>&1; then gpu="true"; fi
 npu="false"; if lspci | grep d801 | grep Huawei  >/dev/null 2>&1; then npu="true"; fi
 echo "${gpu}|${npu}"
 `
-	out, err := client.RunCommand(strings.TrimSpace(probeCmd))
+	res, err := nodeExec.Run(executor.WithRetry(m.ctx), strings.TrimSpace(probeCmd))
 	if err != nil {
 		return false, false, err
 	}
+	out := res.Stdout
 	parts := strings.Split(strings.TrimSpace(out), "|")
 	if len(parts) != 2 {
 		return false, false, fmt.Errorf("unexpected probe output: %s", out)
@@ -947,7 +1368,7 @@ func (m *Manager) deployAscendVNPUDevicePlugin() error {
 }
 
 func (m *Manager) deployHamiWebUI() error {
-	return m.deployHelmAddon("hami-webui", "hami-webui-images", path.Join("hami", "hami-webui"), config.DefaultHamiWebUIChart, "kube-system")
+	return m.deployHelmAddon("hami-webui", "hami-webui-images", path.Join("hami", "hami-webui"), "", "kube-system")
 }
 
 func (m *Manager) checkHelmInstalled() (bool, error) {
@@ -965,16 +1386,12 @@ func (m *Manager) installHelm() error {
 	return err
 }
 
-func (m *Manager) rewriteHelmValuesFile(groupKey, valuesPath string) error {
+// rewriteHelmValuesFile 将 values.yaml 中 images 的镜像仓库替换为私有仓库
+func (m *Manager) rewriteHelmValuesFile(images []string, valuesPath string) error {
 	registryHost, ok := m.registryHost()
 	if !ok {
 		return nil
 	}
-	groups, err := config.ImagesByGroup()
-	if err != nil {
-		return err
-	}
-	images := groups[groupKey]
 	if len(images) == 0 {
 		return nil
 	}
@@ -1035,23 +1452,25 @@ func (m *Manager) runLocalCmd(cmd string) (string, error) {
 }
 
 func (m *Manager) checkClusterStatus() (bool, error) {
-	out, err := m.client.RunCommand("ls /etc/kubernetes/admin.conf")
-	// 注意：对于 Worker 节点，可能没有 admin.conf，可以检查 kubelet.conf
-	if !m.nodeCfg.IsMaster {
-		out, err = m.client.RunCommand("ls /etc/kubernetes/kubelet.conf")
-	} else {
-		if err == nil && out != "" {
-			if !m.isPrimaryExecutionNode() {
-				return true, nil
-			}
-			err = m.generateClusterJoinCommands()
-			if err != nil {
-				return false, err
-			}
+	// Worker 节点没有 admin.conf，检查 kubelet.conf
+	conf := "/etc/kubernetes/kubelet.conf"
+	if m.nodeCfg.IsMaster {
+		conf = "/etc/kubernetes/admin.conf"
+	}
+	// 文件不存在表示未加入集群，命令无法执行（连接失败、取消）时返回错误
+	res, err := m.probe(fmt.Sprintf("ls %s", conf))
+	if err != nil {
+		return false, err
+	}
+	if res.ExitCode != 0 || strings.TrimSpace(res.Stdout) == "" {
+		return false, nil
+	}
+	if m.nodeCfg.IsMaster && m.isPrimaryExecutionNode() {
+		if err := m.generateClusterJoinCommands(); err != nil {
+			return false, err
 		}
 	}
-
-	return err == nil && out != "", nil
+	return true, nil
 }
 
 func (m *Manager) runKubeadm() error {
@@ -1061,7 +1480,7 @@ func (m *Manager) runKubeadm() error {
 			if strings.TrimSpace(m.globalCfg.MasterJoinCommand) == "" {
 				return fmt.Errorf("master join command is required for HA mode")
 			}
-			_, err := m.client.RunCommand(m.globalCfg.MasterJoinCommand)
+			_, err := m.context.StreamCmd(m.globalCfg.MasterJoinCommand)
 			return err
 		}
 		// 主master节点初始化集群
@@ -1080,12 +1499,12 @@ func (m *Manager) runKubeadm() error {
 --kubernetes-version=v%s \
 --image-repository=%s%s`, m.globalCfg.Versions.K8s, repo, controlPlaneEndpoint)
 
-		_, err := m.client.RunCommand(cmd)
+		_, err := m.context.StreamCmd(cmd)
 		if err != nil {
 			return err
 		}
 
-		m.client.RunCommand("mkdir -p $HOME/.kube && cp -f /etc/kubernetes/admin.conf $HOME/.kube/config && chown $(id -u):$(id -g) $HOME/.kube/config")
+		m.runCommand("mkdir -p $HOME/.kube && cp -f /etc/kubernetes/admin.conf $HOME/.kube/config && chown $(id -u):$(id -g) $HOME/.kube/config")
 
 		err = m.generateClusterJoinCommands()
 		if err != nil {
@@ -1097,7 +1516,7 @@ func (m *Manager) runKubeadm() error {
 		// Worker 节点加入集群
 		joinCmd := m.globalCfg.JoinCommand
 		if joinCmd != "" {
-			_, err := m.client.RunCommand(joinCmd)
+			_, err := m.context.StreamCmd(joinCmd)
 			return err
 		}
 	}
@@ -1105,7 +1524,7 @@ func (m *Manager) runKubeadm() error {
 }
 
 func (m *Manager) generateClusterJoinCommands() error {
-	out, err := m.client.RunCommand("kubeadm token create --print-join-command")
+	out, err := m.runCommand("kubeadm token create --print-join-command")
 	if err != nil {
 		return fmt.Errorf("kubeadm token create --print-join-command failed, %s", out)
 	}
@@ -1114,7 +1533,7 @@ func (m *Manager) generateClusterJoinCommands() error {
 	if !m.globalCfg.HA.Enabled {
 		return nil
 	}
-	certOut, err := m.client.RunCommand("kubeadm init phase upload-certs --upload-certs")
+	certOut, err := m.runCommand("kubeadm init phase upload-certs --upload-certs")
 	if err != nil {
 		return fmt.Errorf("kubeadm init phase upload-certs failed, %s", certOut)
 	}
@@ -1,9 +1,13 @@
 package install
 
 import (
+	"context"
+	"errors"
+	"io"
 	"testing"
 
 	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/executor"
 )
 
 func TestIsPrimaryExecutionNode(t *testing.T) {
@@ -55,3 +59,72 @@ func TestIsPrimaryExecutionNode(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectEnvWithFakeExecutor(t *testing.T) {
+	tests := []struct {
+		name      string
+		arch      string
+		probe     string
+		wantArch  string
+		wantOS    string
+		wantNPU   bool
+		cancelled bool
+		wantError bool
+	}{
+		{
+			name:     "ubuntu amd64",
+			arch:     "x86_64",
+			probe:    "Ubuntu|22.04|5.15.0-91-generic|false|false",
+			wantArch: "amd64",
+			wantOS:   "Ubuntu/Debian",
+		},
+		{
+			name:     "openEuler arm64 with ascend",
+			arch:     "aarch64",
+			probe:    "openEuler|22.03|5.10.0-60.18.0.50.oe2203.aarch64|false|true",
+			wantArch: "arm64",
+			wantOS:   "openEuler",
+			wantNPU:  true,
+		},
+		{
+			name:      "unsupported os",
+			arch:      "x86_64",
+			probe:     "Arch Linux||6.7.0|false|false",
+			wantError: true,
+		},
+		{
+			name:      "cancelled",
+			arch:      "x86_64",
+			cancelled: true,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := executor.NewFake().
+				On("uname -m", tt.arch, 0).
+				On("/etc/os-release", tt.probe, 0)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			mgr := NewManagerWithExecutor(ctx, &config.Config{}, &config.NodeConfig{IP: "10.0.0.1"}, fake, 1, 1, io.Discard)
+
+			err := mgr.detectEnv()
+			if (err != nil) != tt.wantError {
+				t.Fatalf("detectEnv() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.cancelled != errors.Is(err, context.Canceled) {
+				t.Fatalf("detectEnv() error = %v, cancelled %v", err, tt.cancelled)
+			}
+			if tt.wantError {
+				return
+			}
+			if mgr.context.Arch != tt.wantArch || mgr.installer.Name() != tt.wantOS || mgr.context.HasNPU != tt.wantNPU {
+				t.Errorf("detectEnv() arch=%s os=%s npu=%v", mgr.context.Arch, mgr.installer.Name(), mgr.context.HasNPU)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/install/strategy"
+)
+
+// packageManifests 进程内共享的资源包 manifest.yaml
+var packageManifests = newFileCache(sourceManifest)
+
+// LoadPackageManifest 读取资源包（或资源目录、镜像）根目录下的 manifest.yaml，没有时返回 nil
+func LoadPackageManifest(pkg string) (*config.PackageManifest, error) {
+	return packageManifests.start(pkg).wait(context.Background())
+}
+
+// readPackageManifest 在资源包中查找 manifest.yaml，结果缓存到旁路的 .manifest.yaml，
+// 资源包中没有 manifest.yaml 时旁路文件为空，避免每次运行都完整扫描资源包
+func readPackageManifest(file string, info os.FileInfo) (*config.PackageManifest, error) {
+	sidecar := file + ".manifest.yaml"
+	if lines, ok := readSidecar(sidecar, info); ok {
+		if len(lines) == 0 {
+			return nil, nil
+		}
+		return config.ParsePackageManifest([]byte(strings.Join(lines, "\n")))
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", file, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			writeSidecar(sidecar, info, "")
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %v", file, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || entryPath(hdr.Name) != config.PackageManifestName {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s in %s: %v", config.PackageManifestName, file, err)
+		}
+		pm, err := config.ParsePackageManifest(data)
+		if err != nil {
+			return nil, err
+		}
+		content := string(data)
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		writeSidecar(sidecar, info, content)
+		return pm, nil
+	}
+}
+
+// LoadCatalog 合并所有资源包 manifest.yaml 中的版本目录，没有 manifest.yaml 的资源包按内置目录处理
+func LoadCatalog(cfg *config.Config) (*config.Catalog, error) {
+	var catalogs []*config.Catalog
+	for _, pkg := range cfg.ResourcePackage.Paths() {
+		pm, err := LoadPackageManifest(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if pm == nil {
+			catalogs = append(catalogs, nil)
+		} else {
+			catalogs = append(catalogs, &pm.Catalog)
+		}
+	}
+	return config.MergeCatalogs(catalogs...)
+}
+
+// osFamily 节点安装策略对应的系统家族
+func osFamily(installer strategy.NodeInstaller) string {
+	switch installer.(type) {
+	case *strategy.UbuntuInstaller:
+		return config.OSFamilyUbuntu
+	case *strategy.FedoraInstaller:
+		return config.OSFamilyFedora
+	default:
+		return config.OSFamilyOpenEuler
+	}
+}
+
+// selectPackage 按节点的系统家族与架构选定资源包，资源包带有 manifest.yaml 时检查是否支持该节点。
+// addons-only 模式只在节点上执行 helm/kubectl，不安装系统软件包，不检查系统家族与架构
+func (m *Manager) selectPackage() error {
+	family, arch := osFamily(m.installer), m.context.Arch
+	supports := func(pm *config.PackageManifest) error {
+		if pm == nil || m.globalCfg.InstallMode == config.InstallModeAddonsOnly {
+			return nil
+		}
+		return pm.CheckNode(family, arch)
+	}
+	pkg, err := m.globalCfg.ResourcePackage.Select(family, arch, func(path string) (bool, error) {
+		pm, err := m.packageManifest(path)
+		return err == nil && pm != nil && supports(pm) == nil, err
+	})
+	if err != nil {
+		return err
+	}
+	m.resourcePackage = pkg
+	if len(m.globalCfg.ResourcePackage) > 1 {
+		fmt.Fprintf(m.output, "[%s]     使用资源包 %s\n", m.nodeCfg.IP, pkg)
+	}
+	// p2p 中继转发的是完整资源包，资源目录与镜像逐节点同步
+	if m.distributor != nil && !isArchive(pkg) {
+		m.distributor = nil
+	}
+	pm, err := m.packageManifest(pkg)
+	if err != nil {
+		return err
+	}
+	return supports(pm)
+}
+
+// catalog 本节点资源包的版本目录，资源包未提供的条目使用内置目录
+func (m *Manager) catalog() (*config.Catalog, error) {
+	pm, err := m.packageManifest(m.resourcePackage)
+	if err != nil {
+		return nil, err
+	}
+	if pm == nil {
+		return config.MergeCatalogs(nil)
+	}
+	return config.MergeCatalogs(&pm.Catalog)
+}
+
+func (m *Manager) packageManifest(pkg string) (*config.PackageManifest, error) {
+	pm, err := packageManifests.start(pkg).wait(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource package manifest: %w", err)
+	}
+	return pm, nil
+}
+
+// CheckPackageSelection 配置了多个资源包或按系统/架构指定资源包时，安装前连接所有节点检测系统与架构，
+// 确认每个节点都有匹配的资源包，避免部分节点安装后才发现缺少资源包
+func CheckPackageSelection(ctx context.Context, cfg *config.Config) error {
+	packages := cfg.ResourcePackage
+	if !packages.NeedsSelection() || cfg.ReplayDir != "" {
+		return nil
+	}
+	if !packages.Keyed() {
+		for _, pkg := range packages.Paths() {
+			pm, err := LoadPackageManifest(pkg)
+			if err != nil {
+				return err
+			}
+			if pm == nil {
+				return fmt.Errorf("%s has no %s, which is required to match nodes when resource_package is a list", pkg, config.PackageManifestName)
+			}
+		}
+	}
+
+	problems := make([]string, len(cfg.Nodes))
+	var wg sync.WaitGroup
+	for i := range cfg.Nodes {
+		node := &cfg.Nodes[i]
+		// addons-only 模式只在 Master 上执行
+		if cfg.InstallMode == config.InstallModeAddonsOnly && !node.IsMaster {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := checkNodePackage(ctx, cfg, node); err != nil {
+				problems[i] = fmt.Sprintf("%s: %v", node.IP, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	problems = slices.DeleteFunc(problems, func(p string) bool { return p == "" })
+	if len(problems) > 0 {
+		return fmt.Errorf("resource package check failed:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// checkNodePackage 单独建立连接检测节点环境，不经过会话记录
+func checkNodePackage(ctx context.Context, cfg *config.Config, node *config.NodeConfig) error {
+	nodeExec, err := dialExecutor(ctx, cfg, node, nil)
+	if err != nil {
+		return fmt.Errorf("ssh connection failed: %w", err)
+	}
+	m := NewManagerWithExecutor(ctx, cfg, node, nodeExec, 0, len(cfg.Nodes), io.Discard)
+	defer m.Close()
+	if err := m.detectEnv(); err != nil {
+		return err
+	}
+	return m.selectPackage()
+}
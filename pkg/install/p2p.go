@@ -0,0 +1,83 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-offline-tool/pkg/ui"
+)
+
+// relayLifetime 中继进程的最长存活时间，工具异常退出时也不会在节点上遗留常驻端口
+const relayLifetime = "4h"
+
+// pullFromPeer 让节点从已就绪节点的中继下载资源包，并校验 sha256 与本机一致
+func (m *Manager) pullFromPeer(nodeCtx *ui.NodeContext, src *peerSource, remotePkgPath, localHash string) error {
+	nodeCtx.UpdateResourceProgress(fmt.Sprintf("正在从节点 %s 拉取 %s ...", src.IP, path.Base(remotePkgPath)))
+
+	tmpPath := remotePkgPath + ".part"
+	pullCmd := fmt.Sprintf(`mkdir -p %[1]s && rm -f %[2]s && \
+if command -v curl >/dev/null 2>&1; then curl -fsS --connect-timeout 10 -o %[2]s %[3]s; \
+elif command -v wget >/dev/null 2>&1; then wget -q -T 10 -O %[2]s %[3]s; \
+else echo "curl or wget is required" >&2; exit 127; fi && \
+sha256sum %[2]s | awk '{print $1}'`, path.Dir(remotePkgPath), tmpPath, src.URL)
+	out, err := m.streamCommand(pullCmd)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if remoteHash := strings.TrimSpace(lines[len(lines)-1]); remoteHash != localHash {
+		m.runCommand(fmt.Sprintf("rm -f %s", tmpPath))
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", localHash, remoteHash)
+	}
+	if _, err := m.runCommand(fmt.Sprintf("mv -f %s %s", tmpPath, remotePkgPath)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startRelay 在节点上启动临时 HTTP 中继，对外只暴露随机路径下的资源包
+func (m *Manager) startRelay(remotePkgPath string) (*peerSource, error) {
+	d := m.distributor
+	relayRoot := path.Join(path.Dir(remotePkgPath), ".relay")
+	relayDir := path.Join(relayRoot, d.token)
+	port := strconv.Itoa(d.port)
+
+	// 根目录放空 index.html，防止目录列表泄露随机路径
+	startCmd := fmt.Sprintf(`command -v python3 >/dev/null 2>&1 || { echo "python3 not found" >&2; exit 127; }
+mkdir -p %[1]s && touch %[2]s/index.html && ln -f %[3]s %[1]s/%[4]s
+cd %[2]s && nohup timeout %[5]s python3 -m http.server %[6]s --bind %[7]s </dev/null >/dev/null 2>&1 &
+pid=$!
+sleep 1
+kill -0 $pid 2>/dev/null || { echo "relay exited, port %[6]s may be in use" >&2; exit 1; }
+echo $pid`, relayDir, relayRoot, remotePkgPath, path.Base(remotePkgPath), relayLifetime, port, m.nodeCfg.IP)
+	res, err := m.exec.Run(m.cmdContext(), startCmd)
+	if err != nil {
+		return nil, err
+	}
+	pid := strings.TrimSpace(res.Stdout)
+	if _, err := strconv.Atoi(pid); err != nil {
+		return nil, fmt.Errorf("unexpected relay pid: %s", res.Text())
+	}
+	fmt.Fprintf(m.output, "[%s]     ⇄ 资源中继已启动 (端口 %s)\n", m.nodeCfg.IP, port)
+
+	globalCfg, nodeCfg := m.globalCfg, m.nodeCfg
+	return &peerSource{
+		IP:  nodeCfg.IP,
+		URL: fmt.Sprintf("http://%s:%s/%s/%s", nodeCfg.IP, port, d.token, path.Base(remotePkgPath)),
+		// 节点的 Manager 在分发结束前可能已关闭，停止时单独建立连接；不随 Ctrl-C 取消
+		stop: func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			nodeExec, err := newExecutor(ctx, globalCfg, nodeCfg, "", nil)
+			if err != nil {
+				return
+			}
+			defer nodeExec.Close()
+			nodeExec.Run(ctx, fmt.Sprintf("kill %s 2>/dev/null; rm -rf %s", pid, relayRoot))
+		},
+	}, nil
+}
@@ -0,0 +1,266 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s-offline-tool/pkg/config"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUnsignedPackage 资源包未签名且未开启 allow_unsigned
+var ErrUnsignedPackage = errors.New("resource package is not signed")
+
+// loadSigningKey 读取 OpenSSH 格式的 ed25519 私钥（ssh-keygen -t ed25519 生成）
+func loadSigningKey(file string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ssh.ParseRawPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key %s: %v", file, err)
+	}
+	key, ok := raw.(*ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", file)
+	}
+	return *key, nil
+}
+
+// SigningPublicKey 私钥对应的 authorized_keys 格式公钥，用于填写 trusted_keys
+func SigningPublicKey(file string) (string, error) {
+	key, err := loadSigningKey(file)
+	if err != nil {
+		return "", err
+	}
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))), nil
+}
+
+// signManifest 签名文件内容为 base64 编码的 ed25519 签名
+func signManifest(key ed25519.PrivateKey, manifest []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)) + "\n")
+}
+
+func verifyManifest(keys []ed25519.PublicKey, manifest, signature []byte) bool {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return false
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, manifest, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyPackage 校验资源包 file 中 manifest.yaml 的签名，并逐个比对资源包中文件的 sha256 与 manifest 记录一致。
+// 校验直接读取资源包内容，不信任旁路文件；通过后本次运行的分发固定使用校验时得到的哈希与文件清单。
+// 资源目录同样逐个比对文件；镜像只校验签名，节点下载每个文件后按签名的 manifest 校验 sha256。
+// 返回 false 表示资源包未签名（或未配置 trusted_keys）且 allow_unsigned 允许跳过校验。
+func VerifyPackage(cfg *config.Config, file string) (bool, error) {
+	keys, err := config.ParseTrustedKeys(cfg.TrustedKeys)
+	if err != nil {
+		return false, err
+	}
+	if len(keys) == 0 && cfg.AllowUnsigned {
+		return false, nil
+	}
+
+	var manifest, signature []byte
+	var files []fileEntry
+	var scan *archiveScan
+	mirror := config.IsMirrorURL(file)
+	switch {
+	case mirror:
+		meta, err := mirrorMetas.start(file).wait(context.Background())
+		if err != nil {
+			return false, err
+		}
+		manifest, signature = meta.manifest, meta.signature
+	case !isArchive(file):
+		if files, err = packageFiles.start(file).wait(context.Background()); err != nil {
+			return false, err
+		}
+		manifest, signature, err = readDirMeta(file)
+	default:
+		if scan, err = scanArchive(file); err == nil {
+			files, manifest, signature = scan.files, scan.manifest, scan.signature
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if manifest == nil || signature == nil {
+		if cfg.AllowUnsigned {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %s has no %s, set allow_unsigned: true to use it anyway", ErrUnsignedPackage, file, config.PackageSignatureName)
+	}
+	if len(keys) == 0 {
+		return false, fmt.Errorf("resource package is signed but no trusted_keys are configured")
+	}
+	if !verifyManifest(keys, manifest, signature) {
+		return false, fmt.Errorf("signature of %s in %s does not match any trusted key", config.PackageManifestName, file)
+	}
+	pm, err := config.ParsePackageManifest(manifest)
+	if err != nil {
+		return false, err
+	}
+	if mirror {
+		return true, nil
+	}
+
+	var problems []string
+	if scan != nil {
+		problems = append(problems, scan.unsafe...)
+	}
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.Path] = true
+		if f.Path == config.PackageManifestName || f.Path == config.PackageSignatureName {
+			continue
+		}
+		want, ok := pm.Files[f.Path]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: not listed in the signed manifest", f.Path))
+		case want != f.SHA256:
+			problems = append(problems, fmt.Sprintf("%s: sha256 %s, signed manifest has %s", f.Path, f.SHA256, want))
+		}
+	}
+	for p := range pm.Files {
+		if !seen[p] {
+			problems = append(problems, fmt.Sprintf("%s: listed in the signed manifest but missing", p))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return false, fmt.Errorf("resource package content does not match its signed manifest:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	// 分发时使用本次校验实际读取的内容，不再信任旁路文件与文件的大小、修改时间
+	hash := sha256.Sum256([]byte(formatFileList(files)))
+	packageHash := hex.EncodeToString(hash[:])
+	if scan != nil {
+		packageHash = scan.hash
+		if info, err := os.Stat(file); err == nil {
+			writeSidecar(file+".sha256", info, packageHash+"  "+filepath.Base(file)+"\n")
+			writeSidecar(file+".files", info, formatFileList(files))
+		}
+	}
+	packageHashes.pin(file, packageHash)
+	packageFiles.pin(file, files)
+	packageManifests.pin(file, pm)
+	return true, nil
+}
+
+// archiveScan 资源包的完整扫描结果
+type archiveScan struct {
+	files     []fileEntry
+	manifest  []byte
+	signature []byte
+	hash      string   // 资源包文件自身的 sha256
+	unsafe    []string // 签名无法覆盖的条目
+}
+
+// scanArchive 读取资源包中全部文件的哈希、manifest.yaml 与签名原文，同时计算资源包自身的 sha256。
+// 签名的 manifest 只记录普通文件的内容，链接、设备等条目以及超出 0755 的权限（setuid、可写等）记入 unsafe；
+// bundle 生成的资源包只有普通文件与目录，权限统一为 0644/0755
+func scanArchive(file string) (*archiveScan, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	packageHash := sha256.New()
+	raw := io.TeeReader(f, packageHash)
+	gz, err := gzip.NewReader(raw)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", file, err)
+	}
+	tr := tar.NewReader(gz)
+	scan := &archiveScan{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %v", file, err)
+		}
+		name := entryPath(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeSymlink, tar.TypeLink:
+			scan.unsafe = append(scan.unsafe, fmt.Sprintf("%s: link to %s is not covered by the signed manifest", name, hdr.Linkname))
+			continue
+		default:
+			scan.unsafe = append(scan.unsafe, fmt.Sprintf("%s: tar entry type %q is not covered by the signed manifest", name, hdr.Typeflag))
+			continue
+		}
+		if hdr.Mode&^0755 != 0 {
+			scan.unsafe = append(scan.unsafe, fmt.Sprintf("%s: mode %04o is not covered by the signed manifest", name, hdr.Mode))
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		var content bytes.Buffer
+		var r io.Reader = tr
+		if name == config.PackageManifestName || name == config.PackageSignatureName {
+			r = io.TeeReader(tr, &content)
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return nil, fmt.Errorf("read %s in %s: %v", hdr.Name, file, err)
+		}
+		scan.files = append(scan.files, fileEntry{Path: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+		switch name {
+		case config.PackageManifestName:
+			scan.manifest = content.Bytes()
+		case config.PackageSignatureName:
+			scan.signature = content.Bytes()
+		}
+	}
+	// tar 结束标记之后可能还有填充字节，读完才是资源包的完整哈希
+	if _, err := io.Copy(io.Discard, raw); err != nil {
+		return nil, fmt.Errorf("read %s: %v", file, err)
+	}
+	scan.hash = hex.EncodeToString(packageHash.Sum(nil))
+	return scan, nil
+}
+
+// readDirMeta 读取资源目录中的 manifest.yaml 与签名，不存在时为 nil
+func readDirMeta(dir string) ([]byte, []byte, error) {
+	var data [2][]byte
+	for i, name := range []string{config.PackageManifestName, config.PackageSignatureName} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		data[i] = content
+	}
+	return data[0], data[1], nil
+}
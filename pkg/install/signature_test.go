@@ -0,0 +1,166 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s-offline-tool/pkg/config"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeSigningKey 生成 OpenSSH 格式的 ed25519 私钥，返回私钥路径与 authorized_keys 格式公钥
+func writeSigningKey(t *testing.T) (string, string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := SigningPublicKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, pub
+}
+
+// rewritePackage 复制资源包，将 name 的内容替换为 content，并在末尾追加 extra 中无内容的条目
+func rewritePackage(t *testing.T, src, name, content string, extra ...*tar.Header) string {
+	t.Helper()
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		if hdr.Name == name {
+			data = []byte(content)
+			hdr.Size = int64(len(data))
+		}
+		tw.WriteHeader(hdr)
+		tw.Write(data)
+	}
+	for _, hdr := range extra {
+		tw.WriteHeader(hdr)
+	}
+	tw.Close()
+	zw.Close()
+	dst := filepath.Join(t.TempDir(), "resources.tar.gz")
+	if err := os.WriteFile(dst, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+func TestVerifyPackage(t *testing.T) {
+	keyFile, pub := writeSigningKey(t)
+	_, otherPub := writeSigningKey(t)
+	staging := writeStaging(t, map[string]string{
+		"VERSION":                          "1.0\n",
+		"k8s/amd64/apt/1-34-4/kubeadm.deb": "deb",
+	})
+	signed := filepath.Join(t.TempDir(), "signed.tar.gz")
+	if _, err := Bundle(BundleOptions{Source: staging, Output: signed, KeyFile: keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := filepath.Join(t.TempDir(), "unsigned.tar.gz")
+	if _, err := Bundle(BundleOptions{Source: staging, Output: unsigned}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		pkg        string
+		keys       []string
+		allow      bool
+		wantSigned bool
+		wantErr    string
+	}{
+		{name: "Trusted signature", pkg: signed, keys: []string{otherPub, pub}, wantSigned: true},
+		{name: "Untrusted signature", pkg: signed, keys: []string{otherPub}, allow: true, wantErr: "does not match any trusted key"},
+		{name: "Signed without trusted keys", pkg: signed, wantErr: "no trusted_keys"},
+		{name: "Unsigned refused", pkg: unsigned, keys: []string{pub}, wantErr: ErrUnsignedPackage.Error()},
+		{name: "Unsigned allowed", pkg: unsigned, keys: []string{pub}, allow: true},
+		{name: "Tampered file", pkg: rewritePackage(t, signed, "VERSION", "2.0\n"), keys: []string{pub}, wantErr: "VERSION: sha256"},
+		{name: "Tampered manifest", pkg: rewritePackage(t, signed, config.PackageManifestName, "name: evil\n"), keys: []string{pub}, wantErr: "does not match any trusted key"},
+		{
+			name:    "Symlink entry",
+			pkg:     rewritePackage(t, signed, "", "", &tar.Header{Name: "etc/cron.d", Typeflag: tar.TypeSymlink, Linkname: "/etc/cron.d"}),
+			keys:    []string{pub},
+			wantErr: "etc/cron.d: link to /etc/cron.d is not covered",
+		},
+		{
+			name:    "Setuid directory",
+			pkg:     rewritePackage(t, signed, "", "", &tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 04755}),
+			keys:    []string{pub},
+			wantErr: "bin: mode 4755 is not covered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{TrustedKeys: tt.keys, AllowUnsigned: tt.allow}
+			got, err := VerifyPackage(cfg, tt.pkg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyPackage() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.wantSigned {
+				t.Fatalf("VerifyPackage() = %v, %v; want %v", got, err, tt.wantSigned)
+			}
+		})
+	}
+
+	if _, err := VerifyPackage(&config.Config{TrustedKeys: []string{pub}}, unsigned); !errors.Is(err, ErrUnsignedPackage) {
+		t.Errorf("err = %v, want ErrUnsignedPackage", err)
+	}
+
+	// 校验通过后分发固定使用校验时读取到的哈希，不信任大小与修改时间一致的旁路文件
+	pinned := rewritePackage(t, signed, "", "")
+	if _, err := VerifyPackage(&config.Config{TrustedKeys: []string{pub}}, pinned); err != nil {
+		t.Fatal(err)
+	}
+	want, err := fileSHA256(pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(pinned)
+	writeSidecar(pinned+".sha256", info, strings.Repeat("ab", 32)+"  resources.tar.gz\n")
+	if got, err := packageHashes.start(pinned).wait(context.Background()); err != nil || got != want {
+		t.Errorf("package hash after verification = %q, %v; want %q", got, err, want)
+	}
+}
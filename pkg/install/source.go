@@ -0,0 +1,251 @@
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/ui"
+)
+
+// 资源来源除 tar.gz 资源包外，还可以是已解压的资源目录，或经 HTTP 发布已解压资源的现场镜像。
+// 目录与镜像都按文件清单逐文件同步，镜像由节点直接下载；分发标记使用文件清单的哈希。
+
+// mirrorTimeout 本机从镜像读取 manifest.yaml 的超时时间
+const mirrorTimeout = 30 * time.Second
+
+// mirrorMetas 进程内共享的镜像 manifest.yaml 与签名原文，文件清单与签名校验使用同一份内容
+var mirrorMetas = newFileCache(fetchMirrorMeta)
+
+// mirrorMeta 镜像根目录下的 manifest.yaml 与 manifest.yaml.sig，没有签名时 signature 为 nil
+type mirrorMeta struct {
+	manifest  []byte
+	signature []byte
+}
+
+// isArchive 资源来源是否为 tar.gz 资源包文件；路径不存在时按资源包处理，由后续读取报错
+func isArchive(src string) bool {
+	if config.IsMirrorURL(src) {
+		return false
+	}
+	info, err := os.Stat(src)
+	return err != nil || !info.IsDir()
+}
+
+// sourceHash 资源包计算文件哈希；目录与镜像以文件清单的哈希作为分发标记
+func sourceHash(src string, info os.FileInfo) (string, error) {
+	if info != nil && !info.IsDir() {
+		return fileHash(src, info)
+	}
+	files, err := packageFiles.start(src).wait(context.Background())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(formatFileList(files)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sourceFileList 资源来源中的全部文件及其哈希
+func sourceFileList(src string, info os.FileInfo) ([]fileEntry, error) {
+	switch {
+	case info == nil:
+		return mirrorFileList(src)
+	case info.IsDir():
+		return dirFileList(src)
+	}
+	return fileList(src, info)
+}
+
+// sourceManifest 资源来源根目录下的 manifest.yaml，没有时返回 nil
+func sourceManifest(src string, info os.FileInfo) (*config.PackageManifest, error) {
+	switch {
+	case info == nil:
+		meta, err := mirrorMetas.start(src).wait(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return config.ParsePackageManifest(meta.manifest)
+	case info.IsDir():
+		data, err := os.ReadFile(filepath.Join(src, config.PackageManifestName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return config.ParsePackageManifest(data)
+	}
+	return readPackageManifest(src, info)
+}
+
+// dirFileList 遍历目录逐个计算普通文件的哈希，按路径排序
+func dirFileList(dir string) ([]fileEntry, error) {
+	var files []fileEntry
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, fileEntry{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", dir, err)
+	}
+	return files, nil
+}
+
+// fetchMirror 读取镜像上的文件，文件不存在时返回 nil
+func fetchMirror(base, name string) ([]byte, error) {
+	client := &http.Client{Timeout: mirrorTimeout}
+	resp, err := client.Get(mirrorURL(base, name))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", mirrorURL(base, name), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func fetchMirrorMeta(base string, _ os.FileInfo) (*mirrorMeta, error) {
+	manifest, err := fetchMirror(base, config.PackageManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("read mirror %s: %v", base, err)
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("mirror %s has no %s, publish an extracted package built by the bundle command", base, config.PackageManifestName)
+	}
+	signature, err := fetchMirror(base, config.PackageSignatureName)
+	if err != nil {
+		return nil, fmt.Errorf("read mirror %s: %v", base, err)
+	}
+	return &mirrorMeta{manifest: manifest, signature: signature}, nil
+}
+
+// mirrorFileList 镜像无法列出目录，文件清单取自 manifest.yaml 的 files；大小未知，记为 0
+func mirrorFileList(base string) ([]fileEntry, error) {
+	pm, err := packageManifests.start(base).wait(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if len(pm.Files) == 0 {
+		return nil, fmt.Errorf("%s on mirror %s lists no files", config.PackageManifestName, base)
+	}
+	files := make([]fileEntry, 0, len(pm.Files))
+	for p, hash := range pm.Files {
+		// 文件路径会拼进节点上的命令，只接受解压目录内的相对路径
+		if p != entryPath(p) || strings.HasPrefix(p, "../") || strings.ContainsAny(p, "\t\n") {
+			return nil, fmt.Errorf("%s on mirror %s has invalid path %q", config.PackageManifestName, base, p)
+		}
+		files = append(files, fileEntry{Path: p, SHA256: hash})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// mirrorURL 镜像上文件的地址，逐段转义路径
+func mirrorURL(base, name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.Join(segments, "/")
+}
+
+// writeDirDelta 将目录中 changed 列出的文件写成 tar.gz，解压时自动创建上级目录
+func writeDirDelta(dir string, changed map[string]string, dst io.Writer) error {
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	zw := gzip.NewWriter(dst)
+	tw := tar.NewWriter(zw)
+	for _, name := range names {
+		if err := addDirFile(tw, dir, name, changed[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addDirFile(tw *tar.Writer, dir, name, want string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// 文件在计算哈希后被改写时内容与清单不一致，报错而不是分发未经校验的内容
+	return copyVerified(tw, io.LimitReader(f, info.Size()), name, want)
+}
+
+// pullFromMirror 节点直接从镜像下载变化的文件，逐个校验 sha256 后替换，校验失败时保留原文件
+func (m *Manager) pullFromMirror(nodeCtx *ui.NodeContext, files []fileEntry, changed map[string]string) error {
+	nodeCtx.UpdateResourceProgress(fmt.Sprintf("正在从镜像下载 %d 个文件...", len(changed)))
+	var list strings.Builder
+	for _, f := range files {
+		if _, ok := changed[f.Path]; ok {
+			fmt.Fprintf(&list, "%s\t%s\t%s\n", f.SHA256, f.Path, mirrorURL(m.resourcePackage, f.Path))
+		}
+	}
+	dir := m.context.RemoteTmpDir
+	cmd := fmt.Sprintf(`mkdir -p %[1]s && cd %[1]s && while IFS="$(printf '\t')" read -r sum file url; do
+mkdir -p "$(dirname "$file")" && rm -f "$file.part" || exit 1
+if command -v curl >/dev/null 2>&1; then curl -fsS --connect-timeout 10 -o "$file.part" "$url" </dev/null
+elif command -v wget >/dev/null 2>&1; then wget -q -T 10 -O "$file.part" "$url" </dev/null
+else echo "curl or wget is required" >&2; exit 127; fi || { rm -f "$file.part"; echo "download $url failed" >&2; exit 1; }
+[ "$(sha256sum "$file.part" | awk '{print $1}')" = "$sum" ] || { rm -f "$file.part"; echo "sha256 mismatch: $file" >&2; exit 1; }
+mv -f "$file.part" "$file" || exit 1
+done`, dir)
+	if _, err := m.exec.RunStdin(m.cmdContext(), cmd, strings.NewReader(list.String())); err != nil {
+		return fmt.Errorf("failed to download resources from mirror: %w", err)
+	}
+	return nil
+}
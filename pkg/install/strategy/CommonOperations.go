@@ -4,13 +4,17 @@ import (
 	"encoding/base64"
 	"fmt"
 	"k8s-offline-tool/pkg/config"
+	"slices"
 	"strings"
 )
 
 // --- System Prep ---
 func CheckSwap(ctx *Context) (bool, error) {
-	out, _ := ctx.RunCmd("swapon --show")
-	return strings.TrimSpace(out) == "", nil
+	res, err := ctx.Probe("swapon --show --noheadings")
+	if err != nil {
+		return false, err
+	}
+	return res.ExitCode == 0 && strings.TrimSpace(res.Stdout) == "", nil
 }
 
 func DisableSwap(ctx *Context) error {
@@ -19,9 +23,14 @@ func DisableSwap(ctx *Context) error {
 	return nil
 }
 
+// CheckKernelModules 模块需已写入开机加载配置；配置文件不存在视为未配置，连接失败时返回错误
 func CheckKernelModules(ctx *Context) (bool, error) {
-	out, err := ctx.RunCmd("cat /etc/modules-load.d/containerd.conf")
-	return err == nil && strings.Contains(out, "overlay") && strings.Contains(out, "br_netfilter"), nil
+	res, err := ctx.Probe("cat /etc/modules-load.d/containerd.conf")
+	if err != nil || res.ExitCode != 0 {
+		return false, err
+	}
+	modules := strings.Fields(res.Stdout)
+	return slices.Contains(modules, "overlay") && slices.Contains(modules, "br_netfilter"), nil
 }
 
 func LoadKernelModules(ctx *Context) error {
@@ -212,7 +221,7 @@ func ConfiguraRegistryContainerd(ctx *Context) error {
 
 	cmd := fmt.Sprintf("cat > /etc/containerd/certs.d/%s/hosts.toml <<EOF\n%s\nEOF", regDomain, hostsToml)
 	if _, err := ctx.RunCmd(cmd); err != nil {
-		return fmt.Errorf("failed to write hosts.toml: %v", err)
+		return fmt.Errorf("failed to write hosts.toml: %w", err)
 	}
 	// 4. 重启服务
 	ctx.RunCmd("systemctl daemon-reload")
@@ -252,22 +261,26 @@ func InstallNerdctl(ctx *Context, vFolder string) error {
 // --- Accelerators ---
 func CheckAcceleratorConfig(ctx *Context) (bool, error) {
 	if ctx.HasGPU {
-		if out, err := ctx.RunCmd("test -e /etc/containerd/conf.d/99-nvidia.toml && echo EXISTS || echo MISSING"); err != nil || strings.TrimSpace(out) == "MISSING" {
+		if ok, err := ctx.Test("-e /etc/containerd/conf.d/99-nvidia.toml"); err != nil || !ok {
 			return false, err
 		}
-		if _, err := ctx.RunCmd("nvidia-container-cli info"); err != nil {
+		// nvidia-container-cli 未安装 (127) 或驱动未就绪时都需要重新配置
+		res, err := ctx.Probe("nvidia-container-cli info")
+		if err != nil || res.ExitCode != 0 {
 			return false, err
 		}
 	}
 
 	if ctx.HasNPU {
-		out, err := ctx.RunCmd("grep \"runtime_type = 'io.containerd.runc.v2'\" /etc/containerd/config.toml")
-		if err != nil || out == "" {
-			return false, nil
-		}
-		out, err = ctx.RunCmd("grep \"BinaryName = '/usr/local/Ascend/Ascend-Docker-Runtime/ascend-docker-runtime'\" /etc/containerd/config.toml")
-		if err != nil || out == "" {
-			return false, nil
+		for _, pattern := range []string{
+			"runtime_type = 'io.containerd.runc.v2'",
+			"BinaryName = '/usr/local/Ascend/Ascend-Docker-Runtime/ascend-docker-runtime'",
+		} {
+			// grep 退出码 1 为未匹配，2 为配置文件不存在，均视为未配置
+			res, err := ctx.Probe(fmt.Sprintf("grep -F %q /etc/containerd/config.toml", pattern))
+			if err != nil || res.ExitCode != 0 {
+				return false, err
+			}
 		}
 	}
 
@@ -281,7 +294,7 @@ func ConfigureNpuContainerRuntime(ctx *Context) error {
 	ctx.RunCmd(fmt.Sprintf("chmod u+x %s/*.run", runtimeDir))
 
 	// 安装 Ascend Docker Runtime
-	ctx.RunCmd(fmt.Sprintf("cd %s && ./*.run --install --install-scene=containerd", runtimeDir))
+	ctx.StreamCmd(fmt.Sprintf("cd %s && ./*.run --install --install-scene=containerd", runtimeDir))
 
 	// 手动修改确认配置项
 	ctx.RunCmd("sed -i \"s|runtime_type = .*|runtime_type = 'io.containerd.runc.v2'|g\" /etc/containerd/config.toml")
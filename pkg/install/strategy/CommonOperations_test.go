@@ -0,0 +1,57 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s-offline-tool/pkg/executor"
+)
+
+func TestCheckKernelModules(t *testing.T) {
+	conf := "overlay\nbr_netfilter\n"
+	tests := []struct {
+		name    string
+		fake    *executor.Fake
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "configured",
+			fake: executor.NewFake().
+				On("containerd.conf", conf, 0),
+			want: true,
+		},
+		{
+			name: "config missing",
+			fake: executor.NewFake().
+				On("containerd.conf", "", 1),
+			want: false,
+		},
+		{
+			name: "br_netfilter not configured",
+			fake: executor.NewFake().
+				On("containerd.conf", "overlay\n", 0),
+			want: false,
+		},
+		{
+			name: "connection lost",
+			fake: executor.NewFake().
+				OnError("containerd.conf", errors.New("connection lost")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &Context{Exec: tt.fake, CmdContext: context.Background}
+			got, err := CheckKernelModules(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckKernelModules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckKernelModules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -57,7 +57,7 @@ func (f *FedoraInstaller) CheckCommonTools() (bool, error) {
 }
 func (f *FedoraInstaller) InstallCommonTools() error {
 	rpmPath := fmt.Sprintf("%s/common-tools/%s/rpm/*.rpm", f.Ctx.RemoteTmpDir, f.Ctx.Arch)
-	_, err := f.Ctx.RunCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
+	_, err := f.Ctx.StreamCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
 	return err
 }
 
@@ -69,7 +69,7 @@ func (f *FedoraInstaller) CheckHAProxy() (bool, error) {
 
 func (f *FedoraInstaller) InstallHAProxy() error {
 	rpmPath := fmt.Sprintf("%s/ha/haproxy/%s/rpm/*.rpm", f.Ctx.RemoteTmpDir, f.Ctx.Arch)
-	_, err := f.Ctx.RunCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
+	_, err := f.Ctx.StreamCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
 	return err
 }
 
@@ -80,7 +80,7 @@ func (f *FedoraInstaller) CheckKeepalived() (bool, error) {
 
 func (f *FedoraInstaller) InstallKeepalived() error {
 	rpmPath := fmt.Sprintf("%s/ha/keepalived/%s/rpm/*.rpm", f.Ctx.RemoteTmpDir, f.Ctx.Arch)
-	_, err := f.Ctx.RunCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
+	_, err := f.Ctx.StreamCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
 	return err
 }
 
@@ -150,7 +150,7 @@ func (f *FedoraInstaller) CheckAcceleratorConfig() (bool, error) {
 func (f *FedoraInstaller) ConfigureAccelerator() error {
 	if f.Ctx.HasGPU {
 		rpmPath := fmt.Sprintf("%s/common-tools/%s/rpm/nvidia-container-toolkit*.rpm", f.Ctx.RemoteTmpDir, f.Ctx.Arch)
-		f.Ctx.RunCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
+		f.Ctx.StreamCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
 		f.Ctx.RunCmd("nvidia-ctk runtime configure --runtime=containerd")
 
 		// default_runtime_name 改为 nvidia
@@ -177,7 +177,7 @@ func (f *FedoraInstaller) InstallK8sComponents() error {
 	vFolder := f.verPath(f.Ctx.Cfg.Versions.K8s)
 	// Path example: k8s/amd64/rpm/1-34-4/*.rpm
 	rpmPath := fmt.Sprintf("%s/k8s/%s/rpm/%s/*.rpm", f.Ctx.RemoteTmpDir, f.Ctx.Arch, vFolder)
-	_, err := f.Ctx.RunCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
+	_, err := f.Ctx.StreamCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
 	f.Ctx.RunCmd("systemctl enable --now kubelet")
 	f.Ctx.RunCmd("systemctl start kubelet")
 	return err
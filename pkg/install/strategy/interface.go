@@ -1,6 +1,11 @@
 package strategy
 
-import "k8s-offline-tool/pkg/config"
+import (
+	"context"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/executor"
+)
 
 type NodeInstaller interface {
 	Name() string
@@ -67,5 +72,48 @@ type Context struct {
 	HasGPU        bool
 	HasNPU        bool
 	RemoteTmpDir  string
-	RunCmd        func(string) (string, error)
+
+	Exec executor.Executor
+	// CmdContext 返回当前命令使用的 context：随 Manager.Run 取消，只读检查阶段可重试
+	CmdContext func() context.Context
+	// OnOutput 接收 StreamCmd 的实时输出，可为空
+	OnOutput func(line string)
+}
+
+// RunCmd 在节点上执行命令，返回合并输出；退出码非 0 也视为错误
+func (c *Context) RunCmd(cmd string) (string, error) {
+	return executor.Output(c.Exec.Run(c.cmdContext(), cmd))
+}
+
+// StreamCmd 与 RunCmd 语义一致，但会将输出实时写入节点日志，用于 dpkg/rpm/kubeadm 等耗时命令
+func (c *Context) StreamCmd(cmd string) (string, error) {
+	return executor.Output(c.Exec.Stream(c.cmdContext(), cmd, c.OnOutput))
+}
+
+// Probe 执行检查命令并返回完整结果：退出码非 0 不视为错误，由调用方按 ExitCode 判断；
+// 仅在命令无法完成（取消、超时、连接断开）时返回错误
+func (c *Context) Probe(cmd string) (*executor.Result, error) {
+	return executor.IgnoreExit(c.Exec.Run(c.cmdContext(), cmd))
+}
+
+// Test 以 test 命令的退出码判断条件：0 为真，1 为假，其他退出码说明命令本身异常
+func (c *Context) Test(expr string) (bool, error) {
+	res, err := c.Probe("test " + expr)
+	if err != nil {
+		return false, err
+	}
+	switch res.ExitCode {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	}
+	return false, &executor.ExitError{Result: res}
+}
+
+func (c *Context) cmdContext() context.Context {
+	if c.CmdContext == nil {
+		return context.Background()
+	}
+	return c.CmdContext()
 }
@@ -71,7 +71,7 @@ func (o *OpenEulerInstaller) CheckHAProxy() (bool, error) {
 
 func (o *OpenEulerInstaller) InstallHAProxy() error {
 	rpmPath := fmt.Sprintf("%s/ha/haproxy/%s/rpm/*.rpm", o.Ctx.RemoteTmpDir, o.Ctx.Arch)
-	_, err := o.Ctx.RunCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
+	_, err := o.Ctx.StreamCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
 	return err
 }
 
@@ -82,7 +82,7 @@ func (o *OpenEulerInstaller) CheckKeepalived() (bool, error) {
 
 func (o *OpenEulerInstaller) InstallKeepalived() error {
 	rpmPath := fmt.Sprintf("%s/ha/keepalived/%s/rpm/*.rpm", o.Ctx.RemoteTmpDir, o.Ctx.Arch)
-	_, err := o.Ctx.RunCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
+	_, err := o.Ctx.StreamCmd(fmt.Sprintf("sudo dnf install -y %s --disablerepo=\"*\" --nogpgcheck", rpmPath))
 	return err
 }
 
@@ -152,7 +152,7 @@ func (o *OpenEulerInstaller) CheckAcceleratorConfig() (bool, error) {
 func (o *OpenEulerInstaller) ConfigureAccelerator() error {
 	if o.Ctx.HasGPU {
 		rpmPath := fmt.Sprintf("%s/common-tools/%s/rpm/nvidia-container-toolkit*.rpm", o.Ctx.RemoteTmpDir, o.Ctx.Arch)
-		o.Ctx.RunCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
+		o.Ctx.StreamCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
 		o.Ctx.RunCmd("nvidia-ctk runtime configure --runtime=containerd")
 		// default_runtime_name 改为 nvidia
 		o.Ctx.RunCmd("sed -i 's/^\\([[:space:]]*default_runtime_name[[:space:]]*=[[:space:]]*\\)\"runc\"/\\1\"nvidia\"/' /etc/containerd/conf.d/99-nvidia.toml")
@@ -177,7 +177,7 @@ func (o *OpenEulerInstaller) CheckK8sComponents() (bool, error) {
 func (o *OpenEulerInstaller) InstallK8sComponents() error {
 	vFolder := o.verPath(o.Ctx.Cfg.Versions.K8s)
 	rpmPath := fmt.Sprintf("%s/k8s/%s/rpm/%s/*.rpm", o.Ctx.RemoteTmpDir, o.Ctx.Arch, vFolder)
-	_, err := o.Ctx.RunCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
+	_, err := o.Ctx.StreamCmd(fmt.Sprintf("rpm -Uvh %s --nodeps --force", rpmPath))
 	o.Ctx.RunCmd("systemctl enable --now kubelet")
 	o.Ctx.RunCmd("systemctl start kubelet")
 	return err
@@ -51,7 +51,7 @@ func (u *UbuntuInstaller) CheckCommonTools() (bool, error) {
 }
 func (u *UbuntuInstaller) InstallCommonTools() error {
 	debPath := fmt.Sprintf("%s/common-tools/%s/apt/*.deb", u.Ctx.RemoteTmpDir, u.Ctx.Arch)
-	_, err := u.Ctx.RunCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
+	_, err := u.Ctx.StreamCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
 	return err
 }
 
@@ -63,7 +63,7 @@ func (u *UbuntuInstaller) CheckHAProxy() (bool, error) {
 
 func (u *UbuntuInstaller) InstallHAProxy() error {
 	debPath := fmt.Sprintf("%s/ha/haproxy/%s/apt/*.deb", u.Ctx.RemoteTmpDir, u.Ctx.Arch)
-	_, err := u.Ctx.RunCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
+	_, err := u.Ctx.StreamCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
 	return err
 }
 
@@ -74,7 +74,7 @@ func (u *UbuntuInstaller) CheckKeepalived() (bool, error) {
 
 func (u *UbuntuInstaller) InstallKeepalived() error {
 	debPath := fmt.Sprintf("%s/ha/keepalived/%s/apt/*.deb", u.Ctx.RemoteTmpDir, u.Ctx.Arch)
-	_, err := u.Ctx.RunCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
+	_, err := u.Ctx.StreamCmd(fmt.Sprintf("dpkg -i %s || sudo apt -f install", debPath))
 	return err
 }
 
@@ -141,7 +141,7 @@ func (u *UbuntuInstaller) CheckAcceleratorConfig() (bool, error) {
 func (u *UbuntuInstaller) ConfigureAccelerator() error {
 	if u.Ctx.HasGPU {
 		debPath := fmt.Sprintf("%s/common-tools/%s/apt/nvidia-container-toolkit*.deb", u.Ctx.RemoteTmpDir, u.Ctx.Arch)
-		u.Ctx.RunCmd(fmt.Sprintf("dpkg -i %s", debPath))
+		u.Ctx.StreamCmd(fmt.Sprintf("dpkg -i %s", debPath))
 		u.Ctx.RunCmd("nvidia-ctk runtime configure --runtime=containerd")
 		// default_runtime_name 改为 nvidia
 		u.Ctx.RunCmd("sed -i 's/^\\([[:space:]]*default_runtime_name[[:space:]]*=[[:space:]]*\\)\"runc\"/\\1\"nvidia\"/' /etc/containerd/conf.d/99-nvidia.toml")
@@ -166,7 +166,7 @@ func (u *UbuntuInstaller) CheckK8sComponents() (bool, error) {
 func (u *UbuntuInstaller) InstallK8sComponents() error {
 	vFolder := u.verPath(u.Ctx.Cfg.Versions.K8s)
 	debPath := fmt.Sprintf("%s/k8s/%s/apt/%s/*.deb", u.Ctx.RemoteTmpDir, u.Ctx.Arch, vFolder)
-	_, err := u.Ctx.RunCmd(fmt.Sprintf("dpkg -i %s", debPath))
+	_, err := u.Ctx.StreamCmd(fmt.Sprintf("dpkg -i %s", debPath))
 	u.Ctx.RunCmd("systemctl enable --now kubelet")
 	u.Ctx.RunCmd("systemctl start kubelet")
 	return err
@@ -0,0 +1,55 @@
+package install
+
+import (
+	"context"
+
+	"k8s-offline-tool/pkg/config"
+	"k8s-offline-tool/pkg/executor"
+)
+
+// UploadLimiter 所有节点共享的本机上传预算：总带宽与同时上传的节点数，
+// 避免大量 Worker 并发上传占满出口带宽导致 SSH 控制流量超时
+type UploadLimiter struct {
+	slots chan struct{} // nil 表示不限制并发
+	rate  *executor.Limiter
+}
+
+// NewUploadLimiter 未配置限速与并发上限时返回 nil，上传不受限制
+func NewUploadLimiter(cfg *config.Config) *UploadLimiter {
+	if cfg.UploadRateLimit == "" && cfg.MaxConcurrentUploads <= 0 {
+		return nil
+	}
+	l := &UploadLimiter{}
+	if cfg.MaxConcurrentUploads > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrentUploads)
+	}
+	if cfg.UploadRateLimit != "" {
+		// 已在配置校验时检查过格式
+		if bytesPerSec, err := config.ParseByteRate(cfg.UploadRateLimit); err == nil {
+			l.rate = executor.NewLimiter(bytesPerSec)
+		}
+	}
+	return l
+}
+
+// acquire 占用一个上传槽位，槽位已满时先调用 onWait 再等待；返回的 ctx 带有共享限速器
+func (l *UploadLimiter) acquire(ctx context.Context, onWait func()) (context.Context, func(), error) {
+	if l == nil {
+		return ctx, func() {}, nil
+	}
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			onWait()
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+		release = func() { <-l.slots }
+	}
+	return executor.WithRateLimit(ctx, l.rate), release, nil
+}
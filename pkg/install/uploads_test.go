@@ -0,0 +1,48 @@
+package install
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s-offline-tool/pkg/config"
+)
+
+func TestUploadLimiterSlots(t *testing.T) {
+	if NewUploadLimiter(&config.Config{}) != nil {
+		t.Fatal("limiter without limits should be nil")
+	}
+
+	l := NewUploadLimiter(&config.Config{MaxConcurrentUploads: 1, UploadRateLimit: "10MB/s"})
+	ctx := context.Background()
+	_, release, err := l.acquire(ctx, func() { t.Error("first upload should not wait") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waited := make(chan struct{})
+	acquired := make(chan struct{})
+	go func() {
+		_, release2, err := l.acquire(ctx, func() { close(waited) })
+		if err == nil {
+			release2()
+		}
+		close(acquired)
+	}()
+	<-waited
+	select {
+	case <-acquired:
+		t.Fatal("second upload acquired slot while first still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	<-acquired
+
+	cancelled, cancel := context.WithCancel(ctx)
+	_, release, _ = l.acquire(ctx, func() {})
+	defer release()
+	cancel()
+	if _, _, err := l.acquire(cancelled, func() {}); err != context.Canceled {
+		t.Errorf("acquire err = %v, want context.Canceled", err)
+	}
+}
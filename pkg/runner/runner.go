@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"context"
+	"errors"
 	"k8s-offline-tool/pkg/ui"
 	"time"
 )
@@ -10,12 +12,18 @@ type Step struct {
 	Name   string
 	Check  func() (bool, error)
 	Action func() error
+	// Resources 步骤用到的资源包组件，节点只会收到其步骤需要的组件
+	Resources []string
 }
 
-func RunPipeline(steps []Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) error {
+// RunPipeline 顺序执行步骤；ctx 取消后不再启动新步骤，执行中的步骤标记为已取消
+func RunPipeline(ctx context.Context, steps []Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) error {
 	var err error
 	for _, step := range steps {
-		if err = runStep(step, prefix, nodeCtx, dryRun); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err = runStep(ctx, step, prefix, nodeCtx, dryRun); err != nil {
 			break
 		}
 	}
@@ -23,7 +31,7 @@ func RunPipeline(steps []Step, prefix string, nodeCtx *ui.NodeContext, dryRun bo
 	return err
 }
 
-func runStep(step Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) error {
+func runStep(ctx context.Context, step Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) error {
 	start := time.Now()
 
 	nodeCtx.StartStep(step.Name)
@@ -31,8 +39,8 @@ func runStep(step Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) err
 	// 1. Check
 	nodeCtx.UpdateStatus(ui.Cyan("🔍 检查中..."))
 	ok, err := step.Check()
-	if err != nil {
-		nodeCtx.EndStep(err, time.Since(start), "")
+	if err = cancelled(ctx, err); err != nil {
+		endStep(nodeCtx, err, time.Since(start))
 		return err
 	}
 
@@ -51,11 +59,27 @@ func runStep(step Step, prefix string, nodeCtx *ui.NodeContext, dryRun bool) err
 
 	// 2. Action
 	nodeCtx.UpdateStatus(ui.Cyan("🚀 正在执行..."))
-	if err := step.Action(); err != nil {
-		nodeCtx.EndStep(err, time.Since(start), "")
+	if err := cancelled(ctx, step.Action()); err != nil {
+		endStep(nodeCtx, err, time.Since(start))
 		return err
 	}
 
 	nodeCtx.EndStep(nil, time.Since(start), "")
 	return nil
 }
+
+// cancelled 步骤执行期间 ctx 被取消时，统一返回取消错误（Check 往往会吞掉命令错误）
+func cancelled(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func endStep(nodeCtx *ui.NodeContext, err error, duration time.Duration) {
+	if errors.Is(err, context.Canceled) {
+		nodeCtx.CancelStep(duration)
+		return
+	}
+	nodeCtx.EndStep(err, duration, "")
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s-offline-tool/pkg/ui"
+)
+
+func TestRunPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nextRan := false
+	steps := []Step{
+		{
+			Name:  "kubeadm init",
+			Check: func() (bool, error) { return false, nil },
+			Action: func() error {
+				cancel()
+				return errors.New("command 'kubeadm init' cancelled")
+			},
+		},
+		{
+			Name:  "deploy addons",
+			Check: func() (bool, error) { return false, nil },
+			Action: func() error {
+				nextRan = true
+				return nil
+			},
+		},
+	}
+
+	nodeCtx := ui.NewNodeContext("10.0.0.1", "Master", len(steps), false)
+	err := RunPipeline(ctx, steps, "", nodeCtx, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunPipeline() error = %v, want context.Canceled", err)
+	}
+	if nextRan {
+		t.Error("steps after cancellation should not run")
+	}
+	if !errors.Is(nodeCtx.Err, ui.ErrCancelled) {
+		t.Errorf("node error = %v, want ErrCancelled", nodeCtx.Err)
+	}
+}
@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AuthConfig 节点 SSH 认证方式，可同时启用多种
+type AuthConfig struct {
+	Password             string
+	PrivateKey           string // 私钥文件路径，支持 ~ 开头
+	PrivateKeyPassphrase string
+	SSHAgent             bool
+	SSHAgentSocket       string // 为空时读取 SSH_AUTH_SOCK
+	KeyboardInteractive  bool   // 使用 Password 应答所有提示
+}
+
+// authMethods 按 公钥(私钥文件/agent) -> 密码 -> keyboard-interactive 的顺序构造认证方式。
+// 返回的 closer 用于释放 agent 连接。
+func (a AuthConfig) authMethods() ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closer := func() {}
+
+	if a.PrivateKey != "" {
+		signer, err := loadPrivateKey(a.PrivateKey, a.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, closer, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if a.SSHAgent {
+		socket := a.SSHAgentSocket
+		if socket == "" {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if socket == "" {
+			return nil, closer, errors.New("ssh_agent enabled but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, closer, fmt.Errorf("connect ssh-agent %s failed: %v", socket, err)
+		}
+		closer = func() { conn.Close() }
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if a.Password != "" {
+		methods = append(methods, ssh.Password(a.Password))
+	}
+
+	if a.KeyboardInteractive {
+		password := a.Password
+		methods = append(methods, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range questions {
+				answers[i] = password
+			}
+			return answers, nil
+		}))
+	}
+
+	if len(methods) == 0 {
+		closer()
+		return nil, func() {}, errors.New("no ssh auth method configured")
+	}
+	return methods, closer, nil
+}
+
+func loadPrivateKey(keyPath, passphrase string) (ssh.Signer, error) {
+	keyPath = expandHome(keyPath)
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read private key %s failed: %v", keyPath, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("private key %s is passphrase protected, private_key_passphrase is required", keyPath)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s failed: %v", keyPath, err)
+	}
+	return signer, nil
+}
+
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
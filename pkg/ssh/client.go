@@ -1,163 +1,519 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+
+	"k8s-offline-tool/pkg/executor"
 )
 
+var _ executor.Executor = (*Client)(nil)
+
 type Client struct {
-	client  *ssh.Client
-	sftp    *sftp.Client
+	opts    Options
 	timeout time.Duration
+	user    string
+	sudo    SudoConfig
+
+	mu     sync.Mutex
+	conn   *connection
+	closed bool
+
+	// 非 root 用户的私有暂存目录及其属主，首次上传或读取时创建
+	stagingMu  sync.Mutex
+	stagingDir string
+	stagingUID uint32
+}
+
+// Hop 一跳跳板机
+type Hop struct {
+	Host string
+	Port int
+	User string
+	Auth AuthConfig
+}
+
+// Options 建立节点连接所需的参数
+type Options struct {
+	Host           string
+	Port           int
+	User           string
+	Auth           AuthConfig
+	HostKey        HostKeyConfig
+	Bastions       []Hop // 按顺序经过的跳板机，为空表示直连
+	Sudo           SudoConfig
+	CommandTimeout time.Duration
+
+	KeepaliveInterval time.Duration // 保活间隔，为 0 使用默认值，小于 0 关闭保活
+	KeepaliveMaxMiss  int           // 连续多少次保活无响应判定断开
+	ReconnectAttempts int           // 断开后的重连次数
+	// Logf 记录保活失败、重连等连接事件，可为空
+	Logf func(format string, args ...any)
 }
 
-func NewClient(ip string, port int, user, password string, commandTimeout time.Duration) (*Client, error) {
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
+func NewClient(ctx context.Context, opts Options) (*Client, error) {
+	if opts.KeepaliveInterval == 0 {
+		opts.KeepaliveInterval = defaultKeepaliveInterval
+	}
+	if opts.KeepaliveMaxMiss <= 0 {
+		opts.KeepaliveMaxMiss = defaultKeepaliveMaxMiss
+	}
+	if opts.ReconnectAttempts < 0 {
+		opts.ReconnectAttempts = 0
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
 	}
 
-	addr := fmt.Sprintf("%s:%d", ip, port)
-	conn, err := ssh.Dial("tcp", addr, config)
+	c := &Client{
+		opts:    opts,
+		timeout: opts.CommandTimeout,
+		user:    opts.User,
+		sudo:    opts.Sudo,
+	}
+	conn, err := c.connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial: %v", err)
+		return nil, err
 	}
+	c.conn = conn
+	return c, nil
+}
 
-	sftpClient, err := sftp.NewClient(conn)
+// connect 建立一条新的传输及 SFTP 会话，并启动保活
+func (c *Client) connect(ctx context.Context) (*connection, error) {
+	client, closers, err := dial(ctx, c.opts)
 	if err != nil {
-		conn.Close()
+		return nil, err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		closeAll(closers)
 		return nil, fmt.Errorf("failed to create sftp client: %v", err)
 	}
 
-	return &Client{
-		client:  conn,
-		sftp:    sftpClient,
-		timeout: commandTimeout,
-	}, nil
+	conn := newConnection(client, sftpClient, closers)
+	if c.opts.KeepaliveInterval > 0 {
+		go conn.keepalive(c.opts.KeepaliveInterval, c.opts.KeepaliveMaxMiss, c.opts.Logf)
+	}
+	return conn, nil
 }
 
-func (c *Client) Close() {
-	if c.sftp != nil {
-		c.sftp.Close()
+// current 返回可用的连接，传输已断开时按 ReconnectAttempts 退避重连
+func (c *Client) current(ctx context.Context) (*connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("ssh client to %s is closed", c.opts.Host)
 	}
-	if c.client != nil {
-		c.client.Close()
+	if c.conn != nil && c.conn.alive() {
+		return c.conn, nil
 	}
+	if c.conn != nil {
+		c.conn.close()
+	}
+
+	var lastErr error
+	backoff := time.Second
+	for attempt := 1; attempt <= c.opts.ReconnectAttempts; attempt++ {
+		c.opts.Logf("SSH 连接已断开，正在重连 (%d/%d)...", attempt, c.opts.ReconnectAttempts)
+		conn, err := c.connect(ctx)
+		if err == nil {
+			c.opts.Logf("SSH 重连成功")
+			c.conn = conn
+			return conn, nil
+		}
+		lastErr = err
+		c.opts.Logf("SSH 重连失败: %v", err)
+		if attempt == c.opts.ReconnectAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("ssh connection to %s lost", c.opts.Host)
+	}
+	return nil, fmt.Errorf("ssh connection to %s lost, reconnect failed: %v", c.opts.Host, lastErr)
 }
 
-// RunCommand 执行远程命令并返回输出 (Stdout + Stderr)
-func (c *Client) RunCommand(cmd string) (string, error) {
-	session, err := c.client.NewSession()
-	if err != nil {
-		return "", err
+// dial 依次经过跳板机建立到目标节点的 SSH 连接，SFTP 与命令执行均复用该隧道。
+// 返回的 closers 需按逆序关闭（先目标节点，再跳板机）。
+func dial(ctx context.Context, opts Options) (*ssh.Client, []func(), error) {
+	hops := append(append([]Hop{}, opts.Bastions...), Hop{
+		Host: opts.Host,
+		Port: opts.Port,
+		User: opts.User,
+		Auth: opts.Auth,
+	})
+
+	var closers []func()
+	var prev *ssh.Client
+	for i, hop := range hops {
+		hostKeyCallback, err := opts.HostKey.callback()
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		authMethods, closeAuth, err := hop.Auth.authMethods()
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		closers = append(closers, closeAuth)
+
+		config := &ssh.ClientConfig{
+			User:            hop.User,
+			Auth:            authMethods,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         10 * time.Second,
+		}
+		port := hop.Port
+		if port == 0 {
+			port = 22
+		}
+		addr := net.JoinHostPort(hop.Host, strconv.Itoa(port))
+
+		var conn *ssh.Client
+		if prev == nil {
+			conn, err = dialContext(ctx, addr, config)
+		} else {
+			conn, err = dialThrough(ctx, prev, addr, config)
+		}
+		if err != nil {
+			closeAll(closers)
+			if i < len(hops)-1 {
+				return nil, nil, fmt.Errorf("failed to dial bastion %s: %w", addr, err)
+			}
+			return nil, nil, fmt.Errorf("failed to dial: %w", err)
+		}
+		closers = append(closers, func() { conn.Close() })
+		prev = conn
 	}
-	defer session.Close()
+	return prev, closers, nil
+}
 
-	type result struct {
-		output []byte
-		err    error
+// dialContext 与 ssh.Dial 相同，但 TCP 建连与握手可被 ctx 取消
+func dialContext(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
 	}
-	resultCh := make(chan result, 1)
-	go func() {
-		output, err := session.CombinedOutput(cmd)
-		resultCh <- result{output: output, err: err}
-	}()
+	return handshake(ctx, netConn, addr, config)
+}
 
-	select {
-	case res := <-resultCh:
-		outStr := string(res.output)
-		if res.err != nil {
-			return outStr, fmt.Errorf("command '%s' failed: %v, output: %s", cmd, res.err, strings.TrimSpace(outStr))
+// dialThrough 经已建立的跳板机连接打开到 addr 的通道并完成握手
+func dialThrough(ctx context.Context, bastion *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	netConn, err := bastion.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-		return strings.TrimSpace(outStr), nil
-	case <-time.After(c.timeout):
-		_ = session.Close()
-		return "", fmt.Errorf("command '%s' timed out after %s", cmd, c.timeout)
+		return nil, err
 	}
+	return handshake(ctx, netConn, addr, config)
 }
 
-// DetectArch 检测远程架构
-func (c *Client) DetectArch() (string, error) {
-	out, err := c.RunCommand("uname -m")
+// handshake 在 netConn 上完成 SSH 握手。握手本身没有超时，卡住的跳板机或不应答的节点会一直阻塞，
+// 因此 ctx 取消或超过 config.Timeout 时关闭连接并返回 ctx 的错误
+func handshake(ctx context.Context, netConn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	conn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() {
+		if err == nil {
+			conn.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake with %s: %w", addr, ctx.Err())
+	}
 	if err != nil {
-		return "", err
+		netConn.Close()
+		return nil, err
 	}
-	if strings.Contains(out, "x86_64") {
-		return "amd64", nil
+	return ssh.NewClient(conn, chans, reqs), nil
+}
+
+func closeAll(closers []func()) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
 	}
-	if strings.Contains(out, "aarch64") {
-		return "arm64", nil
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn != nil {
+		c.removeStagingDir(c.conn)
+		c.conn.close()
 	}
-	return strings.TrimSpace(out), nil
 }
 
-// ProgressReader wraps an io.Reader to track progress
-type ProgressReader struct {
-	Reader     io.Reader
-	Total      int64
-	Current    int64
-	OnProgress func(current, total int64)
+// Run 执行远程命令并返回输出 (Stdout + Stderr)
+// 非 root 用户登录时命令会通过 sudo 提权执行
+func (c *Client) Run(ctx context.Context, cmd string) (*executor.Result, error) {
+	return c.Stream(ctx, cmd, nil)
+}
+
+// Stream 执行远程命令，并在命令运行期间将 Stdout/Stderr 逐行回调给 onLine，
+// 结束后返回完整输出与退出状态，语义与 Run 一致。
+// ctx 取消时向远程进程发送 SIGTERM 并关闭会话。
+// 连接断开时自动重连：会话尚未建立的命令总会重试，执行中断的命令仅在 ctx 经 WithRetry 标记时重跑。
+func (c *Client) Stream(ctx context.Context, cmd string, onLine func(line string)) (*executor.Result, error) {
+	return c.exec(ctx, cmd, nil, onLine)
+}
+
+// RunStdin 执行远程命令并将 stdin 写入其标准输入，输入按 ctx 中的限速器限速。
+// 耗时取决于数据量，不受命令超时限制；输入无法重放，命令开始后连接断开直接报错。
+func (c *Client) RunStdin(ctx context.Context, cmd string, stdin io.Reader) (*executor.Result, error) {
+	return c.exec(ctx, cmd, executor.RateLimited(ctx, &executor.ContextReader{Ctx: ctx, R: stdin}), nil)
+}
+
+func (c *Client) exec(ctx context.Context, cmd string, stdin io.Reader, onLine func(line string)) (*executor.Result, error) {
+	for attempt := 0; ; attempt++ {
+		res, conn, started, err := c.streamOnce(ctx, cmd, stdin, onLine)
+		if err == nil || conn == nil || !isTransportError(err, conn) {
+			return res, err
+		}
+		conn.close()
+		if started && (stdin != nil || !executor.Retryable(ctx)) {
+			return res, fmt.Errorf("command '%s' interrupted by lost connection: %v", cmd, err)
+		}
+		if attempt >= c.opts.ReconnectAttempts {
+			return res, fmt.Errorf("command '%s' failed: connection lost: %v", cmd, err)
+		}
+		c.opts.Logf("命令执行期间连接断开，重连后重试: %s", firstLine(cmd))
+	}
 }
 
-func (pr *ProgressReader) Read(p []byte) (n int, err error) {
-	n, err = pr.Reader.Read(p)
-	pr.Current += int64(n)
-	if pr.OnProgress != nil {
-		pr.OnProgress(pr.Current, pr.Total)
+// streamOnce 在当前连接上执行一次命令，started 表示命令是否已发送到远端
+func (c *Client) streamOnce(ctx context.Context, cmd string, input io.Reader, onLine func(line string)) (*executor.Result, *connection, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, false, fmt.Errorf("command '%s' cancelled: %w", cmd, err)
+	}
+
+	conn, err := c.current(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	session, err := conn.client.NewSession()
+	if err != nil {
+		return nil, conn, false, err
+	}
+	defer session.Close()
+
+	execCmd, stdin := c.wrapCommand(cmd, input)
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+	// 写入标准输入的命令耗时取决于数据量，不设超时
+	var timeout <-chan time.Time
+	if input == nil {
+		timeout = time.After(c.timeout)
+	}
+	capture := executor.NewCapture(onLine)
+	session.Stdout = capture.Stdout()
+	session.Stderr = capture.Stderr()
+
+	resultCh := make(chan error, 1)
+	go func() {
+		resultCh <- session.Run(execCmd)
+	}()
+
+	select {
+	case err := <-resultCh:
+		if err == nil {
+			return capture.Result(cmd, 0), conn, true, nil
+		}
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			res := capture.Result(cmd, exitErr.ExitStatus())
+			return res, conn, true, &executor.ExitError{Result: res}
+		}
+		res := capture.Result(cmd, -1)
+		if isTransportError(err, conn) {
+			return res, conn, true, err
+		}
+		return res, conn, true, fmt.Errorf("command '%s' failed: %v, output: %s", cmd, err, res.Text())
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGTERM)
+		_ = session.Close()
+		return capture.Result(cmd, -1), conn, true, fmt.Errorf("command '%s' cancelled: %w", cmd, ctx.Err())
+	case <-conn.done:
+		return capture.Result(cmd, -1), conn, true, fmt.Errorf("connection lost")
+	case <-timeout:
+		_ = session.Close()
+		return capture.Result(cmd, -1), conn, true, fmt.Errorf("command '%s' timed out after %s", cmd, c.timeout)
 	}
-	return
 }
 
-// WriteFile 将流数据写入远程文件 (Stream Mode)
-// 修复：接收 io.Reader 替代 []byte，解决大文件内存占用和传输阻塞问题
-func (c *Client) WriteFile(remotePath string, src io.Reader) error {
-	return c.WriteFileWithProgress(remotePath, src, 0, nil)
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
 }
 
-func (c *Client) WriteFileWithProgress(remotePath string, src io.Reader, total int64, onProgress func(current, total int64)) error {
+// PutFile 上传文件并回调进度。
+// src 可 Seek 且 total 已知时支持断点续传：远端已有的部分文件经前缀哈希校验一致后从其末尾续写，
+// 上传中途连接断开也会在重连后续传。
+func (c *Client) PutFile(ctx context.Context, remotePath string, src io.Reader, total int64, onProgress func(executor.UploadProgress)) error {
 	// 1. 强制转换为正斜杠
 	remotePath = filepath.ToSlash(remotePath)
 
-	dir := path.Dir(remotePath)
+	// 非 root 用户先上传到私有的暂存目录，再提权移动到目标路径
+	uploadPath := remotePath
+	if c.sudo.Enabled {
+		conn, err := c.current(ctx)
+		if err != nil {
+			return err
+		}
+		if uploadPath, err = c.stagingPath(conn, remotePath); err != nil {
+			return err
+		}
+	}
+
+	seeker, resumable := src.(io.ReadSeeker)
+	resumable = resumable && total > 0
+	for attempt := 0; ; attempt++ {
+		conn, err := c.upload(ctx, uploadPath, src, total, resumable, onProgress)
+		if err == nil {
+			break
+		}
+		if !resumable || conn == nil || ctx.Err() != nil || !isTransportError(err, conn) || attempt >= c.opts.ReconnectAttempts {
+			return err
+		}
+		conn.close()
+		c.opts.Logf("上传期间连接断开，重连后断点续传: %s", remotePath)
+		if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+			return err
+		}
+	}
 
-	// 2. 确保父目录存在
-	_, err := c.RunCommand(fmt.Sprintf("mkdir -p %s", dir))
+	if c.sudo.Enabled {
+		return c.promoteStagedFile(ctx, uploadPath, remotePath)
+	}
+	return nil
+}
+
+func (c *Client) upload(ctx context.Context, uploadPath string, src io.Reader, total int64, resumable bool, onProgress func(executor.UploadProgress)) (*connection, error) {
+	conn, err := c.current(ctx)
 	if err != nil {
-		return fmt.Errorf("mkdir -p %s failed: %v", dir, err)
+		return nil, err
 	}
 
-	// 3. SFTP 创建文件
-	f, err := c.sftp.Create(remotePath)
+	// 2. 确保父目录存在；暂存目录在重连后重新确认未被替换
+	dir := path.Dir(uploadPath)
+	if c.sudo.Enabled {
+		if _, err := c.ensureStagingDir(conn); err != nil {
+			return conn, err
+		}
+	} else if _, err := c.Run(ctx, fmt.Sprintf("mkdir -p %s", dir)); err != nil {
+		return conn, fmt.Errorf("mkdir -p %s failed: %w", dir, err)
+	}
+
+	var offset int64
+	if resumable {
+		offset, err = c.resumeOffset(ctx, conn, uploadPath, src.(io.ReadSeeker), total)
+		if err != nil {
+			return conn, err
+		}
+	}
+
+	// 3. SFTP 打开文件：续传时保留已有内容并定位到末尾
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY
+	}
+	f, err := conn.sftp.OpenFile(uploadPath, flags)
 	if err != nil {
-		return fmt.Errorf("sftp create file %s failed: %v", remotePath, err)
+		return conn, fmt.Errorf("sftp create file %s failed: %w", uploadPath, err)
 	}
 	defer f.Close()
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return conn, fmt.Errorf("sftp seek %s to %d failed: %w", uploadPath, offset, err)
+		}
+	}
 
-	var reader io.Reader = src
+	// 续传前的本地前缀校验不受限速，只限制实际发送的数据
+	var reader io.Reader = executor.RateLimited(ctx, &executor.ContextReader{Ctx: ctx, R: src})
 	if onProgress != nil {
-		reader = &ProgressReader{
-			Reader:     src,
-			Total:      total,
-			OnProgress: onProgress,
+		reader = &executor.ProgressReader{
+			Reader:  reader,
+			Total:   total,
+			Current: offset,
+			OnProgress: func(current, total int64) {
+				onProgress(executor.UploadProgress{Current: current, Total: total, Resumed: offset})
+			},
 		}
 	}
 
 	// 4. 流式拷贝 (Buffer Copy)
 	if _, err := io.Copy(f, reader); err != nil {
-		return fmt.Errorf("sftp transfer failed: %v", err)
+		return conn, fmt.Errorf("sftp transfer failed: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return conn, fmt.Errorf("sftp close file %s failed: %w", uploadPath, err)
+	}
+
+	if !c.sudo.Enabled {
+		conn.sftp.Chmod(uploadPath, 0755)
+	}
+	return conn, nil
+}
+
+// GetFile 读取远程文件写入 dst；非 root 用户先提权复制到暂存目录再读取
+func (c *Client) GetFile(ctx context.Context, remotePath string, dst io.Writer) error {
+	remotePath = filepath.ToSlash(remotePath)
+	conn, err := c.current(ctx)
+	if err != nil {
+		return err
 	}
 
-	f.Chmod(0755)
+	readPath := remotePath
+	if c.sudo.Enabled {
+		if readPath, err = c.stagingPath(conn, remotePath); err != nil {
+			return err
+		}
+		copyCmd := fmt.Sprintf("install -m 0600 -o %s %s %s", shellQuote(c.user), shellQuote(remotePath), shellQuote(readPath))
+		if _, err := c.Run(ctx, copyCmd); err != nil {
+			return fmt.Errorf("stage %s failed: %w", remotePath, err)
+		}
+		defer conn.sftp.Remove(readPath)
+	}
+
+	f, err := conn.sftp.Open(readPath)
+	if err != nil {
+		return fmt.Errorf("sftp open file %s failed: %v", readPath, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(dst, &executor.ContextReader{Ctx: ctx, R: f}); err != nil {
+		return fmt.Errorf("sftp read %s failed: %v", readPath, err)
+	}
 	return nil
 }
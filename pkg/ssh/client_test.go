@@ -0,0 +1,41 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestHandshakeStuckPeer(t *testing.T) {
+	config := &ssh.ClientConfig{User: "root", HostKeyCallback: ssh.InsecureIgnoreHostKey(), Timeout: time.Minute}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		want    error
+	}{
+		{name: "Cancelled", timeout: time.Minute, cancel: true, want: context.Canceled},
+		{name: "Timed out", timeout: 50 * time.Millisecond, want: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 对端从不应答，握手会一直阻塞
+			client, server := net.Pipe()
+			defer server.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+			cfg := *config
+			cfg.Timeout = tt.timeout
+			if _, err := handshake(ctx, client, "10.0.0.1:22", &cfg); !errors.Is(err, tt.want) {
+				t.Errorf("handshake() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy 主机公钥校验策略，零值为 tofu；配置中的取值由调用方转换
+type HostKeyPolicy int
+
+const (
+	HostKeyTOFU     HostKeyPolicy = iota // 首次连接时记录主机公钥，之后严格校验
+	HostKeyStrict                        // 仅信任 known_hosts 中已存在的主机公钥
+	HostKeyInsecure                      // 不校验主机公钥，仅用于实验环境
+)
+
+// HostKeyConfig 主机公钥校验策略
+type HostKeyConfig struct {
+	Policy         HostKeyPolicy
+	KnownHostsFile string // 为空时 strict 使用 ~/.ssh/known_hosts，tofu 使用 ~/.k8s-offline-tool/known_hosts
+}
+
+// 并发的 worker 可能同时向同一个 known_hosts 文件追加记录
+var knownHostsMu sync.Mutex
+
+// DefaultKnownHostsFile 返回策略对应的默认 known_hosts 路径
+func DefaultKnownHostsFile(policy HostKeyPolicy) string {
+	if policy == HostKeyTOFU {
+		return expandHome("~/.k8s-offline-tool/known_hosts")
+	}
+	return expandHome("~/.ssh/known_hosts")
+}
+
+func (h HostKeyConfig) callback() (ssh.HostKeyCallback, error) {
+	policy := h.Policy
+	if policy == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	file := h.KnownHostsFile
+	if file == "" {
+		file = DefaultKnownHostsFile(policy)
+	}
+	file = expandHome(file)
+
+	switch policy {
+	case HostKeyStrict:
+		verify, err := knownhosts.New(file)
+		if err != nil {
+			return nil, fmt.Errorf("load known_hosts %s failed: %v", file, err)
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := verify(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+				return fmt.Errorf("host key for %s (%s %s) not found in %s (host_key_policy: strict)",
+					hostname, key.Type(), ssh.FingerprintSHA256(key), file)
+			}
+			return describeHostKeyError(hostname, key, err)
+		}, nil
+	case HostKeyTOFU:
+		if err := ensureKnownHostsFile(file); err != nil {
+			return nil, err
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			knownHostsMu.Lock()
+			defer knownHostsMu.Unlock()
+
+			// 每次校验都重新读取文件，以便看到其它连接刚记录的公钥
+			verify, err := knownhosts.New(file)
+			if err != nil {
+				return fmt.Errorf("load known_hosts %s failed: %v", file, err)
+			}
+			err = verify(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+				return appendKnownHost(file, hostname, key)
+			}
+			return describeHostKeyError(hostname, key, err)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported host key policy: %d", policy)
+	}
+}
+
+// describeHostKeyError 将公钥不匹配转换为包含指纹对比的错误信息
+func describeHostKeyError(hostname string, key ssh.PublicKey, err error) error {
+	if err == nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+	known := make([]string, 0, len(keyErr.Want))
+	for _, want := range keyErr.Want {
+		known = append(known, fmt.Sprintf("%s %s (%s:%d)", want.Key.Type(), ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+	}
+	return fmt.Errorf("host key mismatch for %s: expected %s, got %s %s; the host may be spoofed, remove the stale entry only if the node was reinstalled",
+		hostname, strings.Join(known, " / "), key.Type(), ssh.FingerprintSHA256(key))
+}
+
+func ensureKnownHostsFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("create known_hosts dir failed: %v", err)
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("create known_hosts %s failed: %v", file, err)
+	}
+	return f.Close()
+}
+
+func appendKnownHost(file, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open known_hosts %s failed: %v", file, err)
+	}
+	defer f.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("record host key for %s failed: %v", hostname, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("convert key failed: %v", err)
+	}
+	return key
+}
+
+func TestHostKeyTOFU(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	cfg := HostKeyConfig{Policy: HostKeyTOFU, KnownHostsFile: file}
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	first := newTestHostKey(t)
+	spoofed := newTestHostKey(t)
+
+	cb, err := cfg.callback()
+	if err != nil {
+		t.Fatalf("callback() failed: %v", err)
+	}
+	if err := cb("10.0.0.1:22", remote, first); err != nil {
+		t.Fatalf("first connect should record host key: %v", err)
+	}
+	if err := cb("10.0.0.1:22", remote, first); err != nil {
+		t.Fatalf("recorded host key should be accepted: %v", err)
+	}
+
+	err = cb("10.0.0.1:22", remote, spoofed)
+	if err == nil {
+		t.Fatal("changed host key should be rejected")
+	}
+	if !strings.Contains(err.Error(), ssh.FingerprintSHA256(first)) || !strings.Contains(err.Error(), ssh.FingerprintSHA256(spoofed)) {
+		t.Errorf("mismatch error should contain both fingerprints, got: %v", err)
+	}
+
+	strict, err := HostKeyConfig{Policy: HostKeyStrict, KnownHostsFile: file}.callback()
+	if err != nil {
+		t.Fatalf("strict callback() failed: %v", err)
+	}
+	if err := strict("10.0.0.1:22", remote, first); err != nil {
+		t.Errorf("strict should accept recorded key: %v", err)
+	}
+	if err := strict("10.0.0.2:22", remote, first); err == nil {
+		t.Error("strict should reject unknown host")
+	}
+}
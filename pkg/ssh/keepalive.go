@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultKeepaliveInterval = 15 * time.Second
+	defaultKeepaliveMaxMiss  = 3
+	defaultReconnectAttempts = 3
+)
+
+// connection 一条到目标节点的 SSH 传输及其上的 SFTP 会话，断开后整体丢弃重建
+type connection struct {
+	client  *ssh.Client
+	sftp    *sftp.Client
+	closers []func()
+
+	done      chan struct{} // 传输断开后关闭
+	closeOnce sync.Once
+	deadOnce  sync.Once
+}
+
+func newConnection(client *ssh.Client, sftpClient *sftp.Client, closers []func()) *connection {
+	conn := &connection{
+		client:  client,
+		sftp:    sftpClient,
+		closers: closers,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		client.Wait()
+		conn.markDead()
+	}()
+	return conn
+}
+
+func (conn *connection) markDead() {
+	conn.deadOnce.Do(func() { close(conn.done) })
+}
+
+func (conn *connection) alive() bool {
+	select {
+	case <-conn.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (conn *connection) close() {
+	conn.closeOnce.Do(func() {
+		if conn.sftp != nil {
+			conn.sftp.Close()
+		}
+		closeAll(conn.closers)
+	})
+	conn.markDead()
+}
+
+// keepalive 周期发送 keepalive@openssh.com 请求，连续 maxMiss 次无响应即判定传输已死并主动关闭，
+// 使后续操作能尽快触发重连，而不是卡在半开的 TCP 连接上
+func (conn *connection) keepalive(interval time.Duration, maxMiss int, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+		if conn.ping(interval) {
+			missed = 0
+			continue
+		}
+		missed++
+		if missed >= maxMiss {
+			logf("SSH 保活连续 %d 次无响应，判定连接已断开", missed)
+			conn.close()
+			return
+		}
+	}
+}
+
+func (conn *connection) ping(timeout time.Duration) bool {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := conn.client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	case <-conn.done:
+		return false
+	}
+}
+
+// isTransportError 判断命令失败是否由连接断开导致（而非命令本身的退出码）
+func isTransportError(err error, conn *connection) bool {
+	if err == nil {
+		return false
+	}
+	var exitMissing *ssh.ExitMissingError
+	if errors.As(err, &exitMissing) || errors.Is(err, io.EOF) {
+		return true
+	}
+	return !conn.alive()
+}
@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s-offline-tool/pkg/executor"
+)
+
+// resumeOffset 返回远端部分文件可续传的偏移。
+// 远端文件的前缀哈希需与本地同长度前缀一致，否则从头上传；
+// 返回时 src 已读到该偏移处。
+func (c *Client) resumeOffset(ctx context.Context, conn *connection, remotePath string, src io.ReadSeeker, total int64) (int64, error) {
+	info, err := conn.sftp.Stat(remotePath)
+	if err != nil || info.Size() == 0 || info.Size() > total {
+		return 0, nil
+	}
+	size := info.Size()
+
+	res, err := c.Run(executor.WithRetry(ctx), fmt.Sprintf("head -c %d %s | sha256sum", size, shellQuote(remotePath)))
+	if err != nil {
+		// 无法校验时不冒险续传
+		return 0, nil
+	}
+	fields := strings.Fields(res.Stdout)
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, &executor.ContextReader{Ctx: ctx, R: src}, size); err != nil {
+		return 0, fmt.Errorf("failed to hash local prefix: %w", err)
+	}
+	if fields[0] == hex.EncodeToString(h.Sum(nil)) {
+		c.opts.Logf("远端已有 %d/%d 字节且校验一致，断点续传", size, total)
+		return size, nil
+	}
+
+	c.opts.Logf("远端部分文件与本地不一致，从头上传")
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
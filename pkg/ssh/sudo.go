@@ -0,0 +1,120 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// SudoConfig 非 root 用户登录时的提权配置
+type SudoConfig struct {
+	Enabled  bool
+	Password string // 为空时使用 sudo -n，要求 NOPASSWD
+}
+
+// wrapCommand 非 root 用户下将命令包装为 sudo 执行，返回实际执行的命令和需要写入的标准输入，
+// input 为命令自身的标准输入，可为 nil
+func (c *Client) wrapCommand(cmd string, input io.Reader) (string, io.Reader) {
+	if !c.sudo.Enabled {
+		return cmd, input
+	}
+	if c.sudo.Password == "" {
+		return fmt.Sprintf("sudo -n -H bash -c %s", shellQuote(cmd)), input
+	}
+	password := strings.NewReader(c.sudo.Password + "\n")
+	if input == nil {
+		// -p '' 关闭提示符，避免污染命令输出；密码经标准输入传入，不出现在进程参数中。
+		// 免密或凭据已缓存时 sudo 不读取密码，命令的标准输入改为 /dev/null，避免读到密码
+		return fmt.Sprintf("sudo -S -p '' -H bash -c %s", shellQuote("exec </dev/null; "+cmd)), password
+	}
+	// 命令需要读取标准输入时，sudo -S 在免密或凭据已缓存时不会读取密码，密码会混入数据。
+	// 改由登录 shell 读取第一行作为密码，经 SUDO_ASKPASS 交给 sudo，其余输入原样交给命令。
+	script := `IFS= read -r pw; a=$(mktemp) && printf '%%s\n' '#!/bin/sh' 'printf "%%s\n" "$K8S_SUDO_PW"' > "$a" && chmod 700 "$a" && ` +
+		`K8S_SUDO_PW="$pw" SUDO_ASKPASS="$a" sudo -A -H bash -c %s; rc=$?; rm -f "$a"; exit $rc`
+	return fmt.Sprintf(script, shellQuote(cmd)), io.MultiReader(password, input)
+}
+
+// stagingPath 返回非 root 用户上传或读取文件时使用的暂存路径，位于本连接私有的暂存目录中
+func (c *Client) stagingPath(conn *connection, remotePath string) (string, error) {
+	dir, err := c.ensureStagingDir(conn)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, path.Base(remotePath)), nil
+}
+
+// ensureStagingDir 与 mktemp -d 相同：以登录用户身份在 /tmp 下新建随机命名的目录并设为 0700。
+// 路径已存在（含符号链接）时 Mkdir 失败，其他用户无法预先创建目录或替换其中待提权移动的文件；
+// 之后每次使用前确认目录仍是本连接创建的那个，重连后同样适用
+func (c *Client) ensureStagingDir(conn *connection) (string, error) {
+	c.stagingMu.Lock()
+	defer c.stagingMu.Unlock()
+	if c.stagingDir != "" {
+		info, err := conn.sftp.Lstat(c.stagingDir)
+		if err != nil {
+			return "", fmt.Errorf("staging dir %s: %v", c.stagingDir, err)
+		}
+		if !info.IsDir() || info.Mode().Perm() != 0700 || fileOwner(info) != c.stagingUID {
+			return "", fmt.Errorf("staging dir %s was replaced, refusing to use it", c.stagingDir)
+		}
+		return c.stagingDir, nil
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	dir := fmt.Sprintf("/tmp/.k8s-offline-staging-%s-%x", c.user, suffix)
+	if err := conn.sftp.Mkdir(dir); err != nil {
+		return "", fmt.Errorf("create staging dir %s failed: %v", dir, err)
+	}
+	if err := conn.sftp.Chmod(dir, 0700); err != nil {
+		conn.sftp.RemoveDirectory(dir)
+		return "", fmt.Errorf("chmod staging dir %s failed: %v", dir, err)
+	}
+	info, err := conn.sftp.Lstat(dir)
+	if err != nil {
+		conn.sftp.RemoveDirectory(dir)
+		return "", fmt.Errorf("staging dir %s: %v", dir, err)
+	}
+	c.stagingDir, c.stagingUID = dir, fileOwner(info)
+	return dir, nil
+}
+
+// fileOwner SFTP 返回的文件属主 uid
+func fileOwner(info os.FileInfo) uint32 {
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		return stat.UID
+	}
+	return 0
+}
+
+// removeStagingDir 关闭连接前删除暂存目录，失败时留给系统清理 /tmp
+func (c *Client) removeStagingDir(conn *connection) {
+	c.stagingMu.Lock()
+	defer c.stagingMu.Unlock()
+	if c.stagingDir != "" {
+		conn.sftp.RemoveAll(c.stagingDir)
+		c.stagingDir = ""
+	}
+}
+
+// promoteStagedFile 以提权方式将暂存文件移动到目标路径
+func (c *Client) promoteStagedFile(ctx context.Context, stagingPath, remotePath string) error {
+	cmd := fmt.Sprintf("mkdir -p %s && mv -f %s %s && chown root:root %s && chmod 755 %s",
+		shellQuote(path.Dir(remotePath)), shellQuote(stagingPath), shellQuote(remotePath), shellQuote(remotePath), shellQuote(remotePath))
+	if _, err := c.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("move staged file to %s failed: %w", remotePath, err)
+	}
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
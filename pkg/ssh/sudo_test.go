@@ -0,0 +1,127 @@
+package ssh
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-offline-tool/pkg/sshtest"
+)
+
+func TestWrapCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		sudo      SudoConfig
+		cmd       string
+		input     string
+		wantCmd   string
+		wantStdin string
+	}{
+		{
+			name:    "root runs command as is",
+			cmd:     "systemctl restart containerd",
+			wantCmd: "systemctl restart containerd",
+		},
+		{
+			name:    "nopasswd sudo",
+			sudo:    SudoConfig{Enabled: true},
+			cmd:     "echo 'a' > /etc/x",
+			wantCmd: `sudo -n -H bash -c 'echo '"'"'a'"'"' > /etc/x'`,
+		},
+		{
+			name:      "sudo password via stdin",
+			sudo:      SudoConfig{Enabled: true, Password: "secret"},
+			cmd:       "kubeadm version",
+			wantCmd:   "sudo -S -p '' -H bash -c 'exec </dev/null; kubeadm version'",
+			wantStdin: "secret\n",
+		},
+		{
+			name:      "nopasswd sudo keeps command input",
+			sudo:      SudoConfig{Enabled: true},
+			cmd:       "tar -xz -C /opt",
+			input:     "data",
+			wantCmd:   "sudo -n -H bash -c 'tar -xz -C /opt'",
+			wantStdin: "data",
+		},
+		{
+			name:      "sudo password with command input uses askpass",
+			sudo:      SudoConfig{Enabled: true, Password: "secret"},
+			cmd:       "tar -xz -C /opt",
+			input:     "data",
+			wantCmd:   `IFS= read -r pw; a=$(mktemp) && printf '%s\n' '#!/bin/sh' 'printf "%s\n" "$K8S_SUDO_PW"' > "$a" && chmod 700 "$a" && K8S_SUDO_PW="$pw" SUDO_ASKPASS="$a" sudo -A -H bash -c 'tar -xz -C /opt'; rc=$?; rm -f "$a"; exit $rc`,
+			wantStdin: "secret\ndata",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{sudo: tt.sudo}
+			var input io.Reader
+			if tt.input != "" {
+				input = strings.NewReader(tt.input)
+			}
+			gotCmd, stdin := c.wrapCommand(tt.cmd, input)
+			if gotCmd != tt.wantCmd {
+				t.Errorf("wrapCommand() cmd = %s, want %s", gotCmd, tt.wantCmd)
+			}
+			gotStdin := ""
+			if stdin != nil {
+				data, _ := io.ReadAll(stdin)
+				gotStdin = string(data)
+			}
+			if gotStdin != tt.wantStdin {
+				t.Errorf("wrapCommand() stdin = %q, want %q", gotStdin, tt.wantStdin)
+			}
+		})
+	}
+}
+
+func TestStagingDir(t *testing.T) {
+	node := sshtest.Ubuntu()
+	node.Root = t.TempDir()
+	os.Mkdir(filepath.Join(node.Root, "tmp"), 0777)
+	srv := sshtest.NewServer(t, "127.0.0.1", node)
+	ctx := context.Background()
+	c, err := NewClient(ctx, Options{
+		Host:    srv.Host,
+		Port:    srv.Port,
+		User:    "deploy",
+		Auth:    AuthConfig{Password: "x"},
+		HostKey: HostKeyConfig{Policy: HostKeyInsecure},
+		Sudo:    SudoConfig{Enabled: true},
+
+		CommandTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	if err := c.PutFile(ctx, "/opt/a", strings.NewReader("a"), 1, nil); err != nil {
+		t.Fatalf("PutFile() error = %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(node.Root, "tmp", ".k8s-offline-staging-deploy-*"))
+	if len(matches) != 1 {
+		t.Fatalf("staging dirs = %v, want one private dir", matches)
+	}
+	if info, err := os.Lstat(matches[0]); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("staging dir mode = %v (%v), want 0700", info.Mode(), err)
+	}
+
+	// 暂存目录被替换为指向其他目录的符号链接后拒绝继续使用
+	other := t.TempDir()
+	os.RemoveAll(matches[0])
+	if err := os.Symlink(other, matches[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutFile(ctx, "/opt/b", strings.NewReader("b"), 1, nil); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Fatalf("PutFile() error = %v, want refusing replaced staging dir", err)
+	}
+	if _, err := os.Stat(filepath.Join(other, "b")); err == nil {
+		t.Errorf("file was written through the replaced staging dir")
+	}
+}
@@ -0,0 +1,203 @@
+// Package sshtest 提供进程内的 SSH + SFTP 服务端，配合可编排的模拟 shell，
+// 用于在不依赖真实主机的情况下端到端测试安装流程。
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Server 监听本地端口的模拟节点，接受任意密码与公钥认证
+type Server struct {
+	Node *Node
+	Host string
+	Port int
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewServer 在 host 的随机端口上启动 node 的 SSH 服务，测试结束时自动关闭。
+// host 可使用 127.0.0.x 的不同地址，使多个模拟节点拥有不同的 IP；地址不可用时跳过测试。
+func NewServer(t testing.TB, host string, node *Node) *Server {
+	t.Helper()
+	if node.Root == "" {
+		node.Root = t.TempDir()
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		// 127.0.0.2 等回环别名只在 Linux 上默认可用，其他系统上跳过需要多个地址的测试
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() && !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Skipf("loopback alias %s is not available: %v", host, err)
+		}
+		t.Fatalf("listen on %s: %v", host, err)
+	}
+	s := &Server{
+		Node:     node,
+		Host:     host,
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		listener: listener,
+		config:   config,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr 返回 host:port
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() {
+	s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+}
+
+// DropConnections 断开当前所有连接但继续监听，用于模拟网络中断后重连
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+
+	// keepalive@openssh.com 等全局请求一律应答
+	go func() {
+		for req := range reqs {
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleSession(channel, requests)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+			s.exec(channel, payload.Command)
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+			server := sftp.NewRequestServer(channel, rootFS{node: s.Node}.handlers())
+			if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+				server.Close()
+			}
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (s *Server) exec(channel ssh.Channel, cmd string) {
+	reply := s.Node.Exec(cmd, channel)
+	// 读完命令未读取的输入（如 sudo -S 的密码），再返回退出码，避免客户端写入失败
+	io.Copy(io.Discard, channel)
+	io.WriteString(channel, reply.Stdout)
+	io.WriteString(channel.Stderr(), reply.Stderr)
+	status := struct{ Status uint32 }{uint32(reply.ExitCode)}
+	channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+}
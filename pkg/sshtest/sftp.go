@@ -0,0 +1,114 @@
+package sshtest
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/sftp"
+)
+
+// rootFS 将 SFTP 请求映射到节点 Root 目录下
+type rootFS struct {
+	node *Node
+}
+
+func (fs rootFS) handlers() sftp.Handlers {
+	return sftp.Handlers{FileGet: fs, FilePut: fs, FileCmd: fs, FileList: fs}
+}
+
+func (fs rootFS) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return os.Open(fs.node.Path(r.Filepath))
+}
+
+func (fs rootFS) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	pflags := r.Pflags()
+	flags := os.O_WRONLY
+	if pflags.Read {
+		flags = os.O_RDWR
+	}
+	if pflags.Append {
+		flags |= os.O_APPEND
+	}
+	if pflags.Creat {
+		flags |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	return os.OpenFile(fs.node.Path(r.Filepath), flags, 0644)
+}
+
+func (fs rootFS) Filecmd(r *sftp.Request) error {
+	p := fs.node.Path(r.Filepath)
+	switch r.Method {
+	case "Setstat":
+		attrs := r.Attributes()
+		if r.AttrFlags().Permissions {
+			if err := os.Chmod(p, attrs.FileMode()); err != nil {
+				return err
+			}
+		}
+		if r.AttrFlags().Size {
+			return os.Truncate(p, int64(attrs.Size))
+		}
+		return nil
+	case "Rename":
+		return os.Rename(p, fs.node.Path(r.Target))
+	case "Rmdir", "Remove":
+		return os.Remove(p)
+	case "Mkdir":
+		return os.Mkdir(p, 0755)
+	case "Symlink":
+		return os.Symlink(r.Filepath, fs.node.Path(r.Target))
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (fs rootFS) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p := fs.node.Path(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		infos := make([]os.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return listerAt(infos), nil
+	case "Stat":
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	case "Lstat":
+		info, err := os.Lstat(p)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,470 @@
+package sshtest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reply 一条命令的模拟执行结果
+type Reply struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// HandlerFunc 应答匹配到的命令，m 为正则子匹配，m[0] 为整条命令
+type HandlerFunc func(n *Node, m []string) Reply
+
+type rule struct {
+	re *regexp.Regexp
+	fn func(n *Node, m []string, stdin io.Reader) Reply
+}
+
+// OSRelease 节点 /etc/os-release 与内核信息
+type OSRelease struct {
+	Name    string
+	Version string
+	Kernel  string
+}
+
+// Node 模拟的目标节点：命令由规则应答，文件落在 Root 目录下，SFTP 与 shell 看到同一份文件系统。
+// 自定义规则优先于内置规则，均未匹配的命令输出为空、退出码为 0。
+type Node struct {
+	OS   OSRelease
+	Arch string // uname -m 输出
+	GPU  bool
+	NPU  bool
+	Root string // 为空时由 NewServer 创建临时目录
+
+	mu       sync.Mutex
+	rules    []rule
+	commands []string
+	releases map[string][]string // namespace -> helm release
+}
+
+// NewNode 创建指定系统与架构的模拟节点
+func NewNode(osRelease OSRelease, arch string) *Node {
+	return &Node{OS: osRelease, Arch: arch, releases: make(map[string][]string)}
+}
+
+func Ubuntu() *Node {
+	return NewNode(OSRelease{Name: "Ubuntu", Version: "22.04", Kernel: "5.15.0-91-generic"}, "x86_64")
+}
+
+func OpenEuler() *Node {
+	return NewNode(OSRelease{Name: "openEuler", Version: "22.03", Kernel: "5.10.0-60.18.0.50.oe2203.aarch64"}, "aarch64")
+}
+
+func Fedora() *Node {
+	return NewNode(OSRelease{Name: "Fedora Linux", Version: "39", Kernel: "6.5.6-300.fc39.x86_64"}, "x86_64")
+}
+
+// Handle 注册自定义规则，pattern 为匹配整条命令的正则
+func (n *Node) Handle(pattern string, fn HandlerFunc) *Node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rules = append(n.rules, rule{re: regexp.MustCompile(pattern), fn: func(n *Node, m []string, _ io.Reader) Reply {
+		return fn(n, m)
+	}})
+	return n
+}
+
+// Respond 命令匹配 pattern 时输出 stdout 并以 exitCode 退出
+func (n *Node) Respond(pattern, stdout string, exitCode int) *Node {
+	return n.Handle(pattern, func(*Node, []string) Reply {
+		return Reply{Stdout: stdout, ExitCode: exitCode}
+	})
+}
+
+// Commands 返回节点上执行过的命令（sudo 包装已去除）
+func (n *Node) Commands() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.commands...)
+}
+
+// Ran 报告是否执行过包含 substr 的命令
+func (n *Node) Ran(substr string) bool {
+	for _, cmd := range n.Commands() {
+		if strings.Contains(cmd, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Path 返回节点路径在本机的实际位置
+func (n *Node) Path(p string) string {
+	return filepath.Join(n.Root, filepath.FromSlash(filepath.Clean("/"+p)))
+}
+
+func (n *Node) ReadFile(p string) ([]byte, error) {
+	return os.ReadFile(n.Path(p))
+}
+
+// WriteFile 在节点上预置文件，父目录不存在时自动创建
+func (n *Node) WriteFile(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(n.Path(p)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(n.Path(p), data, 0644)
+}
+
+func (n *Node) exists(p string) bool {
+	_, err := os.Stat(n.Path(p))
+	return err == nil
+}
+
+// Releases 返回 namespace 下经 helm install 安装的 release
+func (n *Node) Releases(namespace string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.releases[namespace]...)
+}
+
+// Exec 执行一条命令并返回模拟结果，stdin 为命令的标准输入
+func (n *Node) Exec(cmd string, stdin io.Reader) Reply {
+	if m := sudoPattern.FindStringSubmatch(cmd); m != nil {
+		cmd = strings.TrimPrefix(unquote(m[1]), "exec </dev/null; ")
+	}
+
+	n.mu.Lock()
+	n.commands = append(n.commands, cmd)
+	rules := append(slices.Clone(n.rules), builtinRules...)
+	n.mu.Unlock()
+
+	for _, r := range rules {
+		if m := r.re.FindStringSubmatch(cmd); m != nil {
+			return r.fn(n, m, stdin)
+		}
+	}
+	return Reply{}
+}
+
+var (
+	sudoPattern    = regexp.MustCompile(`(?s)^sudo (?:-n|-S -p '') -H bash -c ('.*')$`)
+	heredocPattern = regexp.MustCompile(`^\s*cat\s*(?:>\s*(\S+)\s*)?<<\s*'?(\w+)'?(?:\s*\|\s*(?:sudo\s+)?tee\s+(\S+))?\s*$`)
+)
+
+// builtin 将不读取标准输入的处理函数转换为内置规则
+func builtin(pattern string, fn HandlerFunc) rule {
+	return rule{re: regexp.MustCompile(pattern), fn: func(n *Node, m []string, _ io.Reader) Reply {
+		return fn(n, m)
+	}}
+}
+
+var builtinRules = []rule{
+	builtin(`(?s).*<<.*`, heredoc),
+	builtin(`^uname -m$`, func(n *Node, _ []string) Reply {
+		return Reply{Stdout: n.Arch + "\n"}
+	}),
+	builtin(`(?s)/etc/os-release`, func(n *Node, _ []string) Reply {
+		return Reply{Stdout: fmt.Sprintf("%s|%s|%s|%v|%v\n", n.OS.Name, n.OS.Version, n.OS.Kernel, n.GPU, n.NPU)}
+	}),
+	builtin(`(?s)^gpu=.*lspci.*echo "\$\{gpu\}\|\$\{npu\}"$`, func(n *Node, _ []string) Reply {
+		return Reply{Stdout: fmt.Sprintf("%v|%v\n", n.GPU, n.NPU)}
+	}),
+	builtin(`^cat (\S+)$`, func(n *Node, m []string) Reply {
+		data, err := n.ReadFile(unquote(m[1]))
+		if err != nil {
+			return Reply{Stderr: fmt.Sprintf("cat: %s: No such file or directory\n", m[1]), ExitCode: 1}
+		}
+		return Reply{Stdout: string(data)}
+	}),
+	builtin(`^(?:sudo )?test -([edf]) (\S+)$`, func(n *Node, m []string) Reply {
+		info, err := os.Stat(n.Path(unquote(m[2])))
+		ok := err == nil && (m[1] == "e" || (m[1] == "d") == info.IsDir())
+		if !ok {
+			return Reply{ExitCode: 1}
+		}
+		return Reply{}
+	}),
+	builtin(`^ls (\S+)$`, func(n *Node, m []string) Reply {
+		if !n.exists(m[1]) {
+			return Reply{Stderr: fmt.Sprintf("ls: cannot access '%s': No such file or directory\n", m[1]), ExitCode: 2}
+		}
+		return Reply{Stdout: m[1] + "\n"}
+	}),
+	builtin(`^(?:sudo )?mkdir -p (\S+)$`, func(n *Node, m []string) Reply {
+		if err := os.MkdirAll(n.Path(unquote(m[1])), 0755); err != nil {
+			return Reply{Stderr: err.Error() + "\n", ExitCode: 1}
+		}
+		return Reply{}
+	}),
+	builtin(`^echo '([^']*)' > (\S+)$`, func(n *Node, m []string) Reply {
+		if err := n.WriteFile(m[2], []byte(m[1]+"\n")); err != nil {
+			return Reply{Stderr: err.Error() + "\n", ExitCode: 1}
+		}
+		return Reply{}
+	}),
+	builtin(`^head -c (\d+) (\S+) \| sha256sum$`, headSHA256),
+	builtin(`^sha256sum (\S+) \| awk '\{print \$1\}'$`, FileSHA256),
+	builtin(`^cd (\S+) && tar -xzf (\S+)$`, func(n *Node, m []string) Reply {
+		if err := n.extract(m[1], m[2]); err != nil {
+			return Reply{Stderr: fmt.Sprintf("tar: %v\n", err), ExitCode: 2}
+		}
+		return Reply{}
+	}),
+	builtin(`^kubeadm init phase upload-certs`, func(*Node, []string) Reply {
+		return Reply{Stdout: "[upload-certs] Storing the certificates in Secret \"kubeadm-certs\" in the \"kube-system\" Namespace\n" +
+			"[upload-certs] Using certificate key:\n" + strings.Repeat("0123456789abcdef", 4) + "\n"}
+	}),
+	builtin(`^kubeadm token create --print-join-command$`, func(*Node, []string) Reply {
+		return Reply{Stdout: "kubeadm join 10.0.0.1:6443 --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:" + strings.Repeat("ab", 32) + "\n"}
+	}),
+	builtin(`^kubeadm init\b`, func(n *Node, _ []string) Reply {
+		n.WriteFile("/etc/kubernetes/admin.conf", []byte("apiVersion: v1\nkind: Config\n"))
+		n.WriteFile("/etc/kubernetes/kubelet.conf", []byte("apiVersion: v1\nkind: Config\n"))
+		return Reply{Stdout: "Your Kubernetes control-plane has initialized successfully!\n"}
+	}),
+	builtin(`^kubeadm join\b`, func(n *Node, m []string) Reply {
+		if strings.Contains(m[0], "--control-plane") {
+			n.WriteFile("/etc/kubernetes/admin.conf", []byte("apiVersion: v1\nkind: Config\n"))
+		}
+		n.WriteFile("/etc/kubernetes/kubelet.conf", []byte("apiVersion: v1\nkind: Config\n"))
+		return Reply{Stdout: "This node has joined the cluster\n"}
+	}),
+	builtin(`^helm install (\S+) \S+ -n (\S+)`, func(n *Node, m []string) Reply {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if slices.Contains(n.releases[m[2]], m[1]) {
+			return Reply{Stderr: "Error: INSTALLATION FAILED: cannot re-use a name that is still in use\n", ExitCode: 1}
+		}
+		n.releases[m[2]] = append(n.releases[m[2]], m[1])
+		// CNI 插件的 DaemonSet 就绪后会在节点上写入配置
+		if m[1] == "kube-ovn" {
+			n.WriteFile("/etc/cni/net.d/01-kube-ovn.conflist", []byte("{\"name\": \"kube-ovn\"}\n"))
+		}
+		return Reply{Stdout: fmt.Sprintf("NAME: %s\nNAMESPACE: %s\nSTATUS: deployed\n", m[1], m[2])}
+	}),
+	builtin(`^kubectl apply -f \S+/multus-daemonset-thick\.yml$`, func(n *Node, _ []string) Reply {
+		n.WriteFile("/etc/cni/net.d/00-multus.conf", []byte("{\"name\": \"multus-cni-network\"}\n"))
+		return Reply{Stdout: "daemonset.apps/kube-multus-ds created\n"}
+	}),
+	builtin(`^helm -n (\S+) list -q$`, func(n *Node, m []string) Reply {
+		var out strings.Builder
+		for _, name := range n.Releases(m[1]) {
+			out.WriteString(name + "\n")
+		}
+		return Reply{Stdout: out.String()}
+	}),
+	{re: regexp.MustCompile(`^rm -rf \S+ && mkdir -p (\S+) \S+ && bash -o pipefail -c '\{ tee /dev/fd/3 \| tar -xz -C (\S+) >&2; \} 3>&1 \| sha256sum'$`), fn: streamExtract},
+	builtin(`^cp -al --remove-destination (\S+)/\. (\S+)/ && rm -rf \S+$`, moveInto),
+	builtin(`^rm -f (\S+)$`, func(n *Node, m []string) Reply {
+		os.Remove(n.Path(unquote(m[1])))
+		return Reply{}
+	}),
+	builtin(`^rm -rf (\S+)$`, func(n *Node, m []string) Reply {
+		os.RemoveAll(n.Path(unquote(m[1])))
+		return Reply{}
+	}),
+	{re: regexp.MustCompile(`^cd (\S+) && xargs -0 -r (sha256sum|rm -f) --( 2>/dev/null; true)?$`), fn: xargs},
+	{re: regexp.MustCompile(`(?s)^mkdir -p (\S+) && cd \S+ && while IFS=.+ read -r sum file url; do\n.*\ndone$`), fn: mirrorPull},
+}
+
+// mirrorPull 模拟节点从 HTTP 镜像逐个下载文件：标准输入每行为 "<sha256>\t<路径>\t<地址>"，
+// 经本机真实发起请求，sha256 不一致时不替换原文件
+func mirrorPull(n *Node, m []string, stdin io.Reader) Reply {
+	data, _ := io.ReadAll(stdin)
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		sum, name, url := fields[0], fields[1], fields[2]
+		resp, err := http.Get(url)
+		if err != nil {
+			return Reply{Stderr: fmt.Sprintf("curl: %v\ndownload %s failed\n", err, url), ExitCode: 1}
+		}
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return Reply{Stderr: fmt.Sprintf("curl: (22) %s\ndownload %s failed\n", resp.Status, url), ExitCode: 1}
+		}
+		if fmt.Sprintf("%x", sha256.Sum256(content)) != sum {
+			return Reply{Stderr: fmt.Sprintf("sha256 mismatch: %s\n", name), ExitCode: 1}
+		}
+		if err := n.WriteFile(path.Join(m[1], name), content); err != nil {
+			return Reply{Stderr: err.Error() + "\n", ExitCode: 1}
+		}
+	}
+	return Reply{}
+}
+
+// xargs 模拟 cd DIR && xargs -0 -r sha256sum|rm -f --，文件列表以 NUL 分隔从标准输入读取
+func xargs(n *Node, m []string, stdin io.Reader) Reply {
+	data, _ := io.ReadAll(stdin)
+	var out strings.Builder
+	for _, name := range strings.Split(string(data), "\x00") {
+		if name == "" {
+			continue
+		}
+		file := path.Join(m[1], name)
+		if m[2] == "rm -f" {
+			os.Remove(n.Path(file))
+			continue
+		}
+		if content, err := n.ReadFile(file); err == nil {
+			fmt.Fprintf(&out, "%x  %s\n", sha256.Sum256(content), name)
+		}
+	}
+	return Reply{Stdout: out.String()}
+}
+
+// streamExtract 模拟边接收边解压：标准输入同时送给 tar 与 sha256sum
+func streamExtract(n *Node, m []string, stdin io.Reader) Reply {
+	os.RemoveAll(n.Path(m[2]))
+	h := sha256.New()
+	input := io.TeeReader(stdin, h)
+	if err := n.extractFrom(m[2], input); err != nil {
+		return Reply{Stderr: fmt.Sprintf("tar: %v\n", err), ExitCode: 2}
+	}
+	// tar 读到归档结束即停止，tee 仍会读完剩余输入
+	io.Copy(io.Discard, input)
+	return Reply{Stdout: hex.EncodeToString(h.Sum(nil)) + "  -\n"}
+}
+
+// moveInto 模拟 cp -al --remove-destination SRC/. DST/ && rm -rf SRC：将 SRC 中的文件移入 DST 并覆盖同名文件
+func moveInto(n *Node, m []string) Reply {
+	src, dst := n.Path(m[1]), n.Path(m[2])
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(p, target)
+	})
+	if err != nil {
+		return Reply{Stderr: fmt.Sprintf("cp: %v\n", err), ExitCode: 1}
+	}
+	os.RemoveAll(src)
+	return Reply{}
+}
+
+// heredoc 将脚本中 cat > file <<EOF 与 cat <<EOF | tee file 形式的内容写入文件
+func heredoc(n *Node, m []string) Reply {
+	lines := strings.Split(m[0], "\n")
+	for i := 0; i < len(lines); i++ {
+		start := heredocPattern.FindStringSubmatch(lines[i])
+		if start == nil {
+			continue
+		}
+		end := slices.Index(lines[i+1:], start[2])
+		if end < 0 {
+			return Reply{Stderr: fmt.Sprintf("here-document delimited by end-of-file (wanted `%s')\n", start[2]), ExitCode: 2}
+		}
+		body := lines[i+1 : i+1+end]
+		i += end + 1
+
+		target := start[1]
+		if target == "" {
+			target = start[3]
+		}
+		if target == "" {
+			continue
+		}
+		if err := n.WriteFile(target, []byte(strings.Join(body, "\n")+"\n")); err != nil {
+			return Reply{Stderr: err.Error() + "\n", ExitCode: 1}
+		}
+	}
+	return Reply{}
+}
+
+func headSHA256(n *Node, m []string) Reply {
+	size, _ := strconv.ParseInt(m[1], 10, 64)
+	f, err := os.Open(n.Path(unquote(m[2])))
+	if err != nil {
+		return Reply{Stderr: fmt.Sprintf("head: cannot open '%s' for reading: No such file or directory\n", m[2]), ExitCode: 1}
+	}
+	defer f.Close()
+	h := sha256.New()
+	io.CopyN(h, f, size)
+	return Reply{Stdout: hex.EncodeToString(h.Sum(nil)) + "  -\n"}
+}
+
+// FileSHA256 模拟 sha256sum FILE | awk '{print $1}'，文件不存在时输出为空；
+// 可在自定义规则中调用，先篡改文件再返回真实哈希
+func FileSHA256(n *Node, m []string) Reply {
+	data, err := n.ReadFile(unquote(m[1]))
+	if err != nil {
+		return Reply{Stderr: fmt.Sprintf("sha256sum: %s: No such file or directory\n", m[1])}
+	}
+	sum := sha256.Sum256(data)
+	return Reply{Stdout: hex.EncodeToString(sum[:]) + "\n"}
+}
+
+// extract 将节点 dir 下的 tar.gz 解压到 dir
+func (n *Node) extract(dir, archive string) error {
+	f, err := os.Open(n.Path(filepath.Join(dir, archive)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return n.extractFrom(dir, f)
+}
+
+func (n *Node) extractFrom(dir string, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := n.Path(filepath.Join(dir, hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// unquote 去除 shellQuote 形式的单引号
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return s
+	}
+	return strings.ReplaceAll(s[1:len(s)-1], `'"'"'`, "'")
+}
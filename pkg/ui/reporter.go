@@ -2,9 +2,11 @@ package ui
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +16,9 @@ import (
 	"github.com/vbauerster/mpb/v8/decor"
 )
 
+// ErrCancelled 节点因用户中断而未完成
+var ErrCancelled = errors.New("用户中断，已取消")
+
 var (
 	Cyan   = color.New(color.FgCyan).SprintFunc()
 	Green  = color.New(color.FgGreen).SprintFunc()
@@ -30,6 +35,7 @@ type NodeContext struct {
 	CurrentStepName   string
 	CurrentStepStatus string // New: for dynamic TUI status
 	ResourceProgress  string // New: for real-time resource distribution
+	LastOutput        string // 当前步骤远程命令的最新一行输出
 	Bar               *mpb.Bar
 	LogBuffer         *bytes.Buffer
 	StartTime         time.Time
@@ -65,6 +71,16 @@ func (n *NodeContext) UpdateResourceProgress(progress string) {
 	n.ResourceProgress = progress
 }
 
+// LogOutput 将远程命令的实时输出逐行写入节点日志，并在 TUI 状态行展示最新一行
+func (n *NodeContext) LogOutput(line string) {
+	n.Mu.Lock()
+	defer n.Mu.Unlock()
+	fmt.Fprintf(n.LogBuffer, "[%s]     │ %s\n", n.IP, line)
+	if strings.TrimSpace(line) != "" {
+		n.LastOutput = line
+	}
+}
+
 func (n *NodeContext) StartStep(name string) {
 	n.Mu.Lock()
 	defer n.Mu.Unlock()
@@ -72,12 +88,14 @@ func (n *NodeContext) StartStep(name string) {
 	n.CurrentStepName = name
 	n.CurrentStepStatus = Cyan("🔍 检查中...")
 	n.ResourceProgress = ""
+	n.LastOutput = ""
 }
 
 func (n *NodeContext) EndStep(err error, duration time.Duration, extraStatus string) {
 	n.Mu.Lock()
 	defer n.Mu.Unlock()
 
+	n.LastOutput = ""
 	prefix := fmt.Sprintf("[%s] ", n.IP)
 	stepName := n.CurrentStepName
 	// 40 display width should be enough for most Chinese step names
@@ -105,6 +123,21 @@ func (n *NodeContext) EndStep(err error, duration time.Duration, extraStatus str
 	}
 }
 
+// CancelStep 当前步骤因用户中断（Ctrl-C）而取消
+func (n *NodeContext) CancelStep(duration time.Duration) {
+	n.Mu.Lock()
+	defer n.Mu.Unlock()
+
+	n.LastOutput = ""
+	n.ResourceProgress = ""
+	n.Err = ErrCancelled
+	n.CurrentStepStatus = Yellow("⊘ 已取消")
+	prefix := fmt.Sprintf("[%s] ", n.IP)
+	paddedName := runewidth.FillRight(n.CurrentStepName, 40)
+	paddedStatus := runewidth.FillRight(Yellow("⊘ 已取消"), 15)
+	fmt.Fprintf(n.LogBuffer, "%s%s %s %s (%v)\n", prefix, Cyan("▶ [STEP]"), paddedName, paddedStatus, duration.Round(time.Millisecond))
+}
+
 func (n *NodeContext) Finish(success bool, duration time.Duration) {
 	n.Mu.Lock()
 	defer n.Mu.Unlock()
@@ -130,7 +163,10 @@ func (n *NodeContext) Finish(success bool, duration time.Duration) {
 	}
 }
 
+// Write 可能被连接保活等后台协程调用，需加锁
 func (n *NodeContext) Write(p []byte) (int, error) {
+	n.Mu.Lock()
+	defer n.Mu.Unlock()
 	return n.LogBuffer.Write(p)
 }
 
@@ -213,6 +249,13 @@ func addNodeBar(p *mpb.Progress, node *NodeContext) {
 		node.Mu.Lock()
 		defer node.Mu.Unlock()
 
+		if errors.Is(node.Err, ErrCancelled) {
+			if node.CurrentStepName == "" {
+				return Yellow("⊘ 已取消")
+			}
+			return Yellow(fmt.Sprintf("⊘ 已取消: [%s]", node.CurrentStepName))
+		}
+
 		if node.Err != nil {
 			if node.CurrentStepName == "" {
 				return Red(fmt.Sprintf("✖ 失败: %v", node.Err))
@@ -234,6 +277,8 @@ func addNodeBar(p *mpb.Progress, node *NodeContext) {
 		status := node.CurrentStepStatus
 		if node.ResourceProgress != "" {
 			status = fmt.Sprintf("🚀 %s", node.ResourceProgress)
+		} else if node.LastOutput != "" {
+			status = fmt.Sprintf("%s %s", status, runewidth.Truncate(strings.TrimSpace(node.LastOutput), 60, "..."))
 		}
 		return fmt.Sprintf("⏳ [%02d/%02d] %s: %s", node.CurrentStep, node.TotalSteps, node.CurrentStepName, status)
 	})